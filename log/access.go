@@ -0,0 +1,214 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessRecord describes one request handled by LogRequest.
+type AccessRecord struct {
+	Time       time.Time // when the request started
+	Latency    time.Duration
+	Status     int
+	Method     string
+	Path       string
+	Query      string
+	RequestURI string
+	Proto      string
+	ClientIP   string
+	User       string
+	UserAgent  string
+	Referer    string
+	BytesIn    int64 // request body bytes read by the handler
+	BytesOut   int64 // response body bytes written
+	Hijacked   bool  // the handler took over the connection
+	RequestID  string
+}
+
+// AccessLogFormatter renders the message of an access log entry.
+type AccessLogFormatter func(record *AccessRecord) string
+
+// DefaultAccessLog renders "status method path latency".
+func DefaultAccessLog(record *AccessRecord) string {
+	return fmt.Sprintf("%v %s %s %s", record.Status, record.Method, record.Path, record.Latency.Round(time.Microsecond))
+}
+
+// CommonLogFormat renders the Apache Common Log Format:
+// host ident user [time] "request" status bytes
+//
+// Values are escaped as Apache does, so a client cannot forge fields or
+// lines: quotes and backslashes are preceded by a backslash and other
+// non-printable bytes are written as \xhh.
+func CommonLogFormat(record *AccessRecord) string {
+	return fmt.Sprintf(
+		"%s - %s [%s] \"%s\" %d %s",
+		clfValue(record.ClientIP),
+		clfValue(record.User),
+		record.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		clfEscape(record.Method+" "+record.RequestURI+" "+record.Proto),
+		record.Status,
+		clfBytes(record.BytesOut),
+	)
+}
+
+// CombinedLogFormat renders the Apache Combined Log Format, which is the
+// Common Log Format followed by the quoted referer and user agent.
+func CombinedLogFormat(record *AccessRecord) string {
+	return fmt.Sprintf(
+		"%s \"%s\" \"%s\"",
+		CommonLogFormat(record),
+		clfValue(record.Referer),
+		clfValue(record.UserAgent),
+	)
+}
+
+func clfValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return clfEscape(value)
+}
+
+// clfEscape escapes a value the way Apache's ap_escape_logitem does.
+func clfEscape(value string) string {
+	const hex = "0123456789abcdef"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\v':
+			b.WriteString(`\v`)
+		default:
+			if c < ' ' || c >= 0x7f {
+				b.WriteString(`\x`)
+				b.WriteByte(hex[c>>4])
+				b.WriteByte(hex[c&0xf])
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+func clfBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// accessFields returns the record as structured fields.
+func accessFields(record *AccessRecord) []any {
+	return []any{
+		"status", record.Status,
+		"method", record.Method,
+		"path", record.Path,
+		"query", record.Query,
+		"proto", record.Proto,
+		"latency", record.Latency,
+		"bytes_in", record.BytesIn,
+		"bytes_out", record.BytesOut,
+		"client_ip", record.ClientIP,
+		"user_agent", record.UserAgent,
+	}
+}
+
+// clientIP returns the address of the client. When the peer is a trusted
+// proxy, the Forwarded or X-Forwarded-For chain is walked from the right and
+// the first address that is not a trusted proxy is used.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(peer, trusted) {
+		return host
+	}
+
+	chain := forwardedFor(r.Header.Values("Forwarded"))
+	if len(chain) == 0 {
+		chain = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(chain[i])
+		if err != nil {
+			// Obfuscated or unknown identifiers cannot be checked, stop here.
+			return chain[i]
+		}
+		if !isTrustedProxy(addr, trusted) || i == 0 {
+			return addr.String()
+		}
+	}
+	return host
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor extracts the for= parameters of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				chain = append(chain, forwardedNode(strings.Trim(val, `"`)))
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode strips the port and IPv6 brackets from a Forwarded node.
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
+
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				chain = append(chain, addr)
+			}
+		}
+	}
+	return chain
+}
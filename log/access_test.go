@@ -0,0 +1,252 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	for _, tt := range []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		trusted    []netip.Prefix
+		want       string
+	}{
+		{
+			name:       "no headers",
+			remoteAddr: "203.0.113.7:1234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer ignores X-Forwarded-For",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			trusted:    trusted,
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "no trusted proxies ignores X-Forwarded-For",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "trusted peer",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "rightmost untrusted address",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.66, 198.51.100.1, 10.0.0.2"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "all trusted",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			trusted:    trusted,
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "Forwarded preferred over X-Forwarded-For",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       "for=198.51.100.1;proto=https",
+				"X-Forwarded-For": "192.0.2.66",
+			},
+			trusted: trusted,
+			want:    "198.51.100.1",
+		},
+		{
+			name:       "Forwarded chain",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": "for=192.0.2.66, for=198.51.100.1:4711, for=10.0.0.2"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Forwarded IPv6 with brackets and port",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `for="[2001:db8::1]:4711"`},
+			trusted:    trusted,
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "Forwarded obfuscated identifier",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": "for=_hidden"},
+			trusted:    trusted,
+			want:       "_hidden",
+		},
+		{
+			name:       "IPv6 peer",
+			remoteAddr: "[2001:db8::7]:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			trusted:    trusted,
+			want:       "2001:db8::7",
+		},
+		{
+			name:       "trusted IPv6 peer",
+			remoteAddr: "[fd00::1]:1234",
+			headers:    map[string]string{"X-Forwarded-For": "2001:db8::1"},
+			trusted:    trusted,
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "IPv4-mapped trusted peer",
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := clientIP(r, tt.trusted); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	record := &AccessRecord{
+		Time:       time.Date(2024, time.March, 5, 7, 8, 9, 0, time.FixedZone("", -7*3600)),
+		Latency:    1500 * time.Microsecond,
+		Status:     404,
+		Method:     http.MethodGet,
+		Path:       "/missing",
+		RequestURI: "/missing?q=1",
+		Proto:      "HTTP/1.1",
+		ClientIP:   "192.0.2.1",
+		User:       "frank",
+		UserAgent:  `curl/8.0 "quoted"`,
+		BytesOut:   2326,
+	}
+	empty := &AccessRecord{
+		Time:       record.Time,
+		Status:     204,
+		Method:     http.MethodDelete,
+		RequestURI: "/item",
+		Proto:      "HTTP/2.0",
+	}
+	hostile := &AccessRecord{
+		Time:       record.Time,
+		Status:     400,
+		Method:     "GET\"",
+		RequestURI: "/a\" 200 1\n\\x",
+		Proto:      "HTTP/1.1",
+		ClientIP:   "_hidden\t",
+		User:       "ev il\x00",
+		UserAgent:  "agent\r\n\xff",
+		Referer:    `ref\"`,
+	}
+
+	for _, tt := range []struct {
+		name   string
+		format AccessLogFormatter
+		record *AccessRecord
+		want   string
+	}{
+		{"default", DefaultAccessLog, record, "404 GET /missing 1.5ms"},
+		{
+			"common",
+			CommonLogFormat,
+			record,
+			`192.0.2.1 - frank [05/Mar/2024:07:08:09 -0700] "GET /missing?q=1 HTTP/1.1" 404 2326`,
+		},
+		{
+			"common without values",
+			CommonLogFormat,
+			empty,
+			`- - - [05/Mar/2024:07:08:09 -0700] "DELETE /item HTTP/2.0" 204 -`,
+		},
+		{
+			"combined",
+			CombinedLogFormat,
+			record,
+			`192.0.2.1 - frank [05/Mar/2024:07:08:09 -0700] "GET /missing?q=1 HTTP/1.1" 404 2326 "-" "curl/8.0 \"quoted\""`,
+		},
+		{
+			"combined escaped",
+			CombinedLogFormat,
+			hostile,
+			`_hidden\t - ev il\x00 [05/Mar/2024:07:08:09 -0700] "GET\" /a\" 200 1\n\\x HTTP/1.1" 400 - "ref\\\"" "agent\r\n\xff"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format(tt.record); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogRequestFields(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		opts   []RequestLogOption
+		fields bool
+	}{
+		{"default", nil, false},
+		{"enabled", []RequestLogOption{WithAccessLogFields(true)}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := capture(t)
+			handler := LogRequest(tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/fields-"+tt.name, nil)
+			r.Header.Set(RequestIDHeader, "req-"+tt.name)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			entries := sink.all()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if id, _ := fieldValue(entry, RequestIDField); id != "req-"+tt.name {
+				t.Errorf("request_id = %v", id)
+			}
+			status, ok := fieldValue(entry, "status")
+			if ok != tt.fields {
+				t.Fatalf("status field present = %v, want %v (fields %v)", ok, tt.fields, entry.Fields)
+			}
+			if ok && status != http.StatusTeapot {
+				t.Errorf("status field = %v", status)
+			}
+		})
+	}
+}
+
+func TestCLFEscape(t *testing.T) {
+	for value, want := range map[string]string{
+		"plain /path?q=1": "plain /path?q=1",
+		`say "hi"`:        `say \"hi\"`,
+		`C:\dir`:          `C:\\dir`,
+		"\b\n\r\t\v":      `\b\n\r\t\v`,
+		"\x00\x1b\x7f":    `\x00\x1b\x7f`,
+		"caf\u00e9":       `caf\xc3\xa9`,
+	} {
+		if got := clfEscape(value); got != want {
+			t.Errorf("clfEscape(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type OverflowPolicy int
+
+const (
+	// Block makes the logging goroutine wait for room in the queue.
+	Block OverflowPolicy = iota
+	// DropNewest discards the entry being logged when the queue is full,
+	// before any hook sees it. PANIC entries wait for room instead.
+	DropNewest
+	// DropOldest discards the oldest queued entry to make room.
+	DropOldest
+)
+
+// AsyncConfig configures the asynchronous pipeline enabled by EnableAsync.
+type AsyncConfig struct {
+	// QueueSize is the number of entries held for the writer. Defaults to 4096.
+	QueueSize int
+	Policy    OverflowPolicy
+}
+
+type queuedEntry struct {
+	entry *Entry
+	seq   uint64
+}
+
+// asyncQueue is a bounded FIFO of entries drained by a single writer
+// goroutine. Every accepted entry gets a sequence number so Flush can wait
+// for exactly the entries queued before it was called.
+type asyncQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+
+	policy  OverflowPolicy
+	entries []queuedEntry
+	head    int
+	size    int
+
+	lastSeq    uint64    // sequence number of the newest accepted entry
+	inFlight   uint64    // sequence number being written, 0 when idle
+	dropped    uint64    // total entries dropped
+	reported   uint64    // dropped count already reported in an entry
+	lastReport time.Time // when dropped entries were last reported
+	closed     bool
+	waiters    []flushWaiter
+	done       chan struct{}
+}
+
+// dropReportInterval limits how often dropped entries are reported while the
+// queue stays busy; once it drains, any unreported drops are reported at once.
+const dropReportInterval = time.Second
+
+type flushWaiter struct {
+	seq  uint64
+	done chan struct{}
+}
+
+var (
+	asyncMu sync.RWMutex
+	queue   *asyncQueue
+)
+
+// EnableAsync moves writing to sinks onto a background goroutine, so logging
+// calls only pay for queueing. PANIC entries are still written before the
+// panic is raised. Call Flush before exiting to drain the queue.
+func EnableAsync(config AsyncConfig) {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 4096
+	}
+
+	q := &asyncQueue{
+		policy:  config.Policy,
+		entries: make([]queuedEntry, config.QueueSize),
+		done:    make(chan struct{}),
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+
+	asyncMu.Lock()
+	old := queue
+	queue = q
+	asyncMu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+	go q.run()
+}
+
+// DisableAsync writes all queued entries and returns to synchronous writing.
+func DisableAsync() {
+	asyncMu.Lock()
+	old := queue
+	queue = nil
+	asyncMu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+}
+
+// AsyncDropped returns the number of entries dropped because the queue was full.
+func AsyncDropped() uint64 {
+	asyncMu.RLock()
+	q := queue
+	asyncMu.RUnlock()
+
+	if q == nil {
+		return 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Flush waits until every entry logged before the call has been written,
+// then flushes all sinks and hooks. It returns early with ctx's error if ctx
+// is done.
+func Flush(ctx context.Context) error {
+	asyncMu.RLock()
+	q := queue
+	asyncMu.RUnlock()
+
+	if q != nil {
+		if err := q.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	sinksMu.RLock()
+	current := sinks
+	sinksMu.RUnlock()
+
+	var errs []error
+	for _, s := range current {
+		if err := s.sink.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush sink '%s': %w", s.name, err))
+		}
+	}
+	errs = append(errs, flushHooks(ctx)...)
+	return errors.Join(errs...)
+}
+
+// deliver fires the hooks and writes an entry to every registered sink,
+// bypassing sampling, or queues it for the writer goroutine when async mode
+// is enabled. Hooks always run on the logging goroutine, and only for
+// entries the queue accepts; one accepted and later discarded by DropOldest
+// has still been seen by the hooks.
+func deliver(entry *Entry) {
+	asyncMu.RLock()
+	q := queue
+	asyncMu.RUnlock()
+
+	queued := false
+	if q != nil {
+		var accepted bool
+		if queued, accepted = q.push(entry); !accepted {
+			return
+		}
+	}
+	fireHooks(entry)
+
+	if !queued {
+		deliverNow(entry)
+		return
+	}
+
+	if entry.Level == PANIC {
+		q.wait(context.Background())
+	}
+}
+
+// deliverNow records the entry in the recent-entry ring and writes it to
+// the sinks registered at that moment. Both happen under recentMu, so
+// InitFileLogging can replay the ring and register the file sink without an
+// entry being written twice or missed.
+func deliverNow(entry *Entry) {
+	recentMu.Lock()
+	recordRecent(entry)
+	sinksMu.RLock()
+	current := sinks
+	sinksMu.RUnlock()
+	recentMu.Unlock()
+
+	writeSinks(current, entry)
+}
+
+// push queues an entry according to the overflow policy. accepted is false
+// if the entry was dropped; queued is false if the queue has been closed
+// and the caller must write the entry itself.
+func (q *asyncQueue) push(entry *Entry) (queued, accepted bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.size == len(q.entries) && !q.closed {
+		switch {
+		case q.policy == DropNewest && entry.Level != PANIC:
+			q.dropped++
+			return false, false
+		case q.policy == DropOldest:
+			q.head = (q.head + 1) % len(q.entries)
+			q.size--
+			q.dropped++
+			q.notifyWaiters()
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return false, true
+	}
+
+	q.lastSeq++
+	q.entries[(q.head+q.size)%len(q.entries)] = queuedEntry{entry: entry, seq: q.lastSeq}
+	q.size++
+	q.notEmpty.Signal()
+	return true, true
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+
+	for {
+		q.mu.Lock()
+		for q.size == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.size == 0 {
+			q.mu.Unlock()
+			return
+		}
+
+		next := q.entries[q.head]
+		q.entries[q.head] = queuedEntry{}
+		q.head = (q.head + 1) % len(q.entries)
+		q.size--
+		q.inFlight = next.seq
+		q.notFull.Signal()
+
+		var report *Entry
+		now := time.Now()
+		if q.dropped > q.reported && (q.size == 0 || now.Sub(q.lastReport) >= dropReportInterval) {
+			report = &Entry{
+				Time:    now,
+				Level:   WARN,
+				Message: fmt.Sprintf("Dropped %d log entries because the queue was full", q.dropped-q.reported),
+				File:    "???",
+			}
+			q.reported = q.dropped
+			q.lastReport = now
+		}
+		q.mu.Unlock()
+
+		deliverNow(next.entry)
+		if report != nil {
+			deliverNow(report)
+		}
+
+		q.mu.Lock()
+		q.inFlight = 0
+		q.notifyWaiters()
+		q.mu.Unlock()
+	}
+}
+
+// pending returns the lowest sequence number not yet written or dropped.
+// Callers must hold q.mu.
+func (q *asyncQueue) pending() uint64 {
+	if q.inFlight != 0 {
+		return q.inFlight
+	}
+	if q.size > 0 {
+		return q.entries[q.head].seq
+	}
+	return q.lastSeq + 1
+}
+
+// notifyWaiters releases Flush calls whose entries are all written.
+// Callers must hold q.mu.
+func (q *asyncQueue) notifyWaiters() {
+	pending := q.pending()
+	remaining := q.waiters[:0]
+	for _, w := range q.waiters {
+		if w.seq < pending {
+			close(w.done)
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	q.waiters = remaining
+}
+
+func (q *asyncQueue) wait(ctx context.Context) error {
+	q.mu.Lock()
+	if q.pending() > q.lastSeq {
+		q.mu.Unlock()
+		return nil
+	}
+	w := flushWaiter{seq: q.lastSeq, done: make(chan struct{})}
+	q.waiters = append(q.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting entries and waits for the queued ones to be written.
+func (q *asyncQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+
+	<-q.done
+}
@@ -0,0 +1,257 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedSink blocks every write until it is opened, so tests can fill the
+// async queue while the writer goroutine is stuck on the first entry. Tests
+// defer open so DisableAsync can drain the queue when they end.
+type gatedSink struct {
+	captureSink
+	started chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+}
+
+func newGatedSink(t *testing.T) *gatedSink {
+	t.Helper()
+	s := &gatedSink{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	name := "gated-" + t.Name()
+	AddSink(name, s, WithLevel(DEBUG))
+	t.Cleanup(func() { RemoveSink(name) })
+	return s
+}
+
+func (s *gatedSink) Write(entry *Entry, line string) error {
+	s.captureSink.Write(entry, line)
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+	<-s.gate
+	return nil
+}
+
+func (s *gatedSink) open() { s.once.Do(func() { close(s.gate) }) }
+
+// waitStarted waits until the writer goroutine is blocked in Write.
+func (s *gatedSink) waitStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the writer goroutine never reached the sink")
+	}
+}
+
+// enableAsync enables async mode for the rest of the test. It must be
+// called after the sinks are added, so it is disabled before they are
+// removed.
+func enableAsync(t *testing.T, config AsyncConfig) {
+	t.Helper()
+	EnableAsync(config)
+	t.Cleanup(DisableAsync)
+}
+
+// countHook counts the entries whose message starts with prefix.
+func countHook(t *testing.T, prefix string) *atomic.Int64 {
+	t.Helper()
+	var n atomic.Int64
+	AddHook(t.Name(), HookFunc(func(entry *Entry) error {
+		if strings.HasPrefix(entry.Message, prefix) {
+			n.Add(1)
+		}
+		return nil
+	}))
+	t.Cleanup(func() { RemoveHook(t.Name()) })
+	return &n
+}
+
+func TestAsyncOverflowPolicies(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  OverflowPolicy
+		want    []string
+		dropped uint64
+	}{
+		{"Block", Block, []string{"entry 0", "entry 1", "entry 2", "entry 3"}, 0},
+		{"DropNewest", DropNewest, []string{"entry 0", "entry 1", "entry 2"}, 1},
+		{"DropOldest", DropOldest, []string{"entry 0", "entry 2", "entry 3"}, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newGatedSink(t)
+			defer sink.open()
+			fired := countHook(t, "entry ")
+			enableAsync(t, AsyncConfig{QueueSize: 2, Policy: tt.policy})
+
+			Info("entry 0")
+			sink.waitStarted(t)
+
+			// Two entries fill the queue; the third overflows it.
+			logged := make(chan struct{})
+			go func() {
+				defer close(logged)
+				for i := 1; i <= 3; i++ {
+					Infof("entry %d", i)
+				}
+			}()
+
+			if tt.policy == Block {
+				select {
+				case <-logged:
+					t.Fatal("logging did not block on a full queue")
+				case <-time.After(50 * time.Millisecond):
+				}
+			} else {
+				<-logged
+				if got := AsyncDropped(); got != tt.dropped {
+					t.Errorf("AsyncDropped() = %d, want %d", got, tt.dropped)
+				}
+			}
+
+			sink.open()
+			<-logged
+			if err := Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var written []string
+			var reports []string
+			for _, message := range sink.messages() {
+				if strings.HasPrefix(message, "entry ") {
+					written = append(written, message)
+				} else if strings.HasPrefix(message, "Dropped ") {
+					reports = append(reports, message)
+				}
+			}
+			if !slices.Equal(written, tt.want) {
+				t.Errorf("written %q, want %q", written, tt.want)
+			}
+			if got := AsyncDropped(); got != tt.dropped {
+				t.Errorf("AsyncDropped() = %d, want %d", got, tt.dropped)
+			}
+
+			var wantReports []string
+			if tt.dropped > 0 {
+				wantReports = []string{fmt.Sprintf("Dropped %d log entries because the queue was full", tt.dropped)}
+			}
+			if !slices.Equal(reports, wantReports) {
+				t.Errorf("reports = %q, want %q", reports, wantReports)
+			}
+
+			// Hooks see the entries the queue accepted, including one
+			// DropOldest discarded afterwards.
+			wantFired := int64(4)
+			if tt.policy == DropNewest {
+				wantFired = 3
+			}
+			if got := fired.Load(); got != wantFired {
+				t.Errorf("hook fired %d times, want %d", got, wantFired)
+			}
+		})
+	}
+}
+
+func TestAsyncFlushWaitsForEarlierEntries(t *testing.T) {
+	sink := newGatedSink(t)
+	defer sink.open()
+	enableAsync(t, AsyncConfig{QueueSize: 16})
+
+	Info("flush 0")
+	sink.waitStarted(t)
+	Info("flush 1")
+	Info("flush 2")
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- Flush(context.Background()) }()
+
+	select {
+	case err := <-flushed:
+		t.Fatalf("Flush returned %v while entries were pending", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sink.open()
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush did not return once the entries were written")
+	}
+	if got := sink.messages(); !slices.Equal(got, []string{"flush 0", "flush 1", "flush 2"}) {
+		t.Errorf("written %q by the time Flush returned", got)
+	}
+}
+
+func TestAsyncFlushHonoursContext(t *testing.T) {
+	sink := newGatedSink(t)
+	defer sink.open()
+	enableAsync(t, AsyncConfig{QueueSize: 16})
+
+	Info("cancelled flush")
+	sink.waitStarted(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Flush() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAsyncReportsDropsWhileQueueIsBusy(t *testing.T) {
+	sink := newGatedSink(t)
+	defer sink.open()
+	enableAsync(t, AsyncConfig{QueueSize: 2, Policy: DropNewest})
+
+	// step lets the blocked write finish and waits for the next one.
+	step := func() {
+		t.Helper()
+		sink.gate <- struct{}{}
+		sink.waitStarted(t)
+	}
+	report := "Dropped 1 log entries because the queue was full"
+
+	Info("entry 0")
+	sink.waitStarted(t)
+	for i := 1; i <= 3; i++ {
+		Infof("entry %d", i)
+	}
+
+	// The drop is reported right after the next entry, while entry 2 is
+	// still queued.
+	step()
+	step()
+	if got, want := sink.messages(), []string{"entry 0", "entry 1", report}; !slices.Equal(got, want) {
+		t.Fatalf("written %q, want %q", got, want)
+	}
+
+	// Another drop within dropReportInterval waits for the queue to drain.
+	Info("entry 4")
+	Info("entry 5")
+	step()
+	if got := sink.messages(); got[len(got)-1] != "entry 2" {
+		t.Fatalf("written %q, want no report before the queue drained", got)
+	}
+	step()
+	step()
+
+	sink.open()
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"entry 0", "entry 1", report, "entry 2", "entry 4", report}
+	if got := sink.messages(); !slices.Equal(got, want) {
+		t.Errorf("written %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package log_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lunarhue/libs-go/log"
+)
+
+// These tests live outside package log so that their frames are not
+// skipped when the logger looks for the caller of its middleware.
+
+// globalSink records the entries that pass the global level and level spec.
+type globalSink struct {
+	mu      sync.Mutex
+	entries []*log.Entry
+}
+
+func addGlobalSink(t *testing.T) *globalSink {
+	t.Helper()
+	s := &globalSink{}
+	name := "global-" + strings.ReplaceAll(t.Name(), "/", "-")
+	log.AddSink(name, s)
+	t.Cleanup(func() { log.RemoveSink(name) })
+	return s
+}
+
+func (s *globalSink) Write(entry *log.Entry, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *globalSink) Flush() error { return nil }
+func (s *globalSink) Close() error { return nil }
+
+func (s *globalSink) levels(level log.LogLevel) []*log.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found []*log.Entry
+	for _, entry := range s.entries {
+		if entry.Level == level {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+func TestAccessEntriesUseLogRequestCaller(t *testing.T) {
+	handler := log.LogRequest()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		spec   string
+		logged bool
+	}{
+		{"", true},
+		// net/http used to be taken for the caller, so http* dropped them.
+		{"httpapi=debug,http*=warn", true},
+		{"log_test=warn", false},
+		{"caller_test=warn", false},
+	} {
+		t.Run(tt.spec, func(t *testing.T) {
+			if err := log.SetLevelSpec(tt.spec); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { log.SetLevelSpec("") })
+			sink := addGlobalSink(t)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+
+			entries := sink.levels(log.REQUEST)
+			if logged := len(entries) == 1; logged != tt.logged {
+				t.Fatalf("logged %d access entries, want logged = %v", len(entries), tt.logged)
+			}
+			if tt.logged && entries[0].File != "caller_test.go" {
+				t.Errorf("access entry caller = %s:%d, want the LogRequest call", entries[0].File, entries[0].Line)
+			}
+		})
+	}
+}
+
+func TestRecoveredPanicUsesPanickingFrame(t *testing.T) {
+	var want int
+	for _, tt := range []struct {
+		name    string
+		handler func()
+	}{
+		{"panic", func() {
+			_, _, want, _ = runtime.Caller(0)
+			panic("boom")
+		}},
+		{"runtime error", func() {
+			var m map[string]int
+			_, _, want, _ = runtime.Caller(0)
+			m["x"] = 1
+		}},
+		{"log.Panic", func() {
+			_, _, want, _ = runtime.Caller(0)
+			log.Panic("logged then panicked")
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := addGlobalSink(t)
+			handler := log.Recover()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler()
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			var recovered *log.Entry
+			for _, entry := range sink.levels(log.ERROR) {
+				if strings.HasPrefix(entry.Message, "Panic serving") {
+					recovered = entry
+				}
+			}
+			if recovered == nil {
+				t.Fatal("panic was not logged")
+			}
+			if recovered.File != "caller_test.go" || recovered.Line != want+1 {
+				t.Errorf("caller = %s:%d, want caller_test.go:%d", recovered.File, recovered.Line, want+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureSink records the entries written to it.
+type captureSink struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+// capture registers a sink receiving every level for the rest of the test.
+func capture(t *testing.T) *captureSink {
+	t.Helper()
+	s := &captureSink{}
+	name := "capture-" + strings.ReplaceAll(t.Name(), "/", "-")
+	AddSink(name, s, WithLevel(DEBUG))
+	t.Cleanup(func() { RemoveSink(name) })
+	return s
+}
+
+func (s *captureSink) Write(entry *Entry, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *captureSink) Flush() error { return nil }
+func (s *captureSink) Close() error { return nil }
+
+// all returns the entries written so far.
+func (s *captureSink) all() []*Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Entry(nil), s.entries...)
+}
+
+// messages returns the messages of the entries written so far.
+func (s *captureSink) messages() []string {
+	var messages []string
+	for _, entry := range s.all() {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+// fieldValue returns the value of the entry's field named key.
+func fieldValue(entry *Entry, key string) (any, bool) {
+	for _, field := range entry.Fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// captureStderr redirects os.Stderr to a file until the test ends and
+// returns a function reading what was written so far. Only use it while no
+// other goroutine reports errors, as os.Stderr is swapped unsynchronized.
+func captureStderr(t *testing.T) func() string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "stderr")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stderr
+	os.Stderr = file
+	t.Cleanup(func() {
+		os.Stderr = saved
+		file.Close()
+	})
+	return func() string { return readFile(t, name) }
+}
@@ -2,16 +2,129 @@ package log
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"sync"
+	"time"
+	"unicode/utf8"
 )
 
-// LogCommand attaches the logger to the command's stdout/stderr.
-// It returns a 'wait' function that you must call after cmd.Wait() returns.
-// valid loggers: log.Infof, log.Warnf, etc.
-func LogCommand(cmd *exec.Cmd, name string) (func(), error) {
+// CommandOption configures how LogCommand and RunCommand log a command.
+type CommandOption func(*commandConfig)
+
+// WithStdoutLevel sets the level of lines read from stdout. Defaults to INFO.
+// PANIC is logged as ERROR.
+func WithStdoutLevel(level LogLevel) CommandOption {
+	return func(c *commandConfig) {
+		c.stdoutLevel = level
+	}
+}
+
+// WithStderrLevel sets the level of lines read from stderr. Defaults to WARN.
+// PANIC is logged as ERROR.
+func WithStderrLevel(level LogLevel) CommandOption {
+	return func(c *commandConfig) {
+		c.stderrLevel = level
+	}
+}
+
+// WithCommandPrefix replaces the "[name] " prefix of every line. An empty
+// prefix logs lines as they are.
+func WithCommandPrefix(prefix string) CommandOption {
+	return func(c *commandConfig) {
+		c.prefix = prefix
+	}
+}
+
+// WithCommandColor colours the prefix with an ANSI escape sequence on
+// coloured consoles. Other sinks get the prefix uncoloured.
+func WithCommandColor(color string) CommandOption {
+	return func(c *commandConfig) {
+		c.color = color
+	}
+}
+
+// WithMaxLineLength splits lines longer than n bytes into several entries,
+// at UTF-8 boundaries. n <= 0 keeps lines whole however long they are.
+// Defaults to 64KB.
+func WithMaxLineLength(n int) CommandOption {
+	return func(c *commandConfig) {
+		c.maxLine = n
+	}
+}
+
+// WithCommandLogger logs through logger, so lines carry its name and fields.
+func WithCommandLogger(logger *Logger) CommandOption {
+	return func(c *commandConfig) {
+		c.logger = logger
+	}
+}
+
+// WithCommandStatus controls whether RunCommand logs the start of the
+// command, with its pid and arguments, and its exit, with the exit code,
+// signal and duration. Enabled by default.
+func WithCommandStatus(enabled bool) CommandOption {
+	return func(c *commandConfig) {
+		c.status = enabled
+	}
+}
+
+type commandConfig struct {
+	name        string
+	stdoutLevel LogLevel
+	stderrLevel LogLevel
+	prefix      string
+	color       string
+	maxLine     int
+	logger      *Logger
+	status      bool
+	format      OutputFormat
+
+	// Caller of LogCommand or RunCommand, reported for every line since the
+	// lines are logged from goroutines of this package.
+	file string
+	line int
+	pkg  string
+}
+
+func newCommandConfig(name string, opts []CommandOption) *commandConfig {
+	c := &commandConfig{
+		name:        name,
+		stdoutLevel: INFO,
+		stderrLevel: WARN,
+		prefix:      "[" + name + "] ",
+		maxLine:     bufio.MaxScanTokenSize,
+		logger:      std,
+		status:      true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.stdoutLevel == PANIC {
+		c.stdoutLevel = ERROR
+	}
+	if c.stderrLevel == PANIC {
+		c.stderrLevel = ERROR
+	}
+	if c.logger == nil {
+		c.logger = std
+	}
+	c.file, c.line, c.pkg = findCaller()
+	return c
+}
+
+// LogCommand attaches the logger to the command's stdout/stderr. Call it
+// before cmd.Start. It returns a 'wait' function that blocks until both
+// streams are closed and every line is logged; call it before cmd.Wait,
+// which closes the pipes. RunCommand does all of this.
+func LogCommand(cmd *exec.Cmd, name string, opts ...CommandOption) (func(), error) {
+	return logCommand(cmd, newCommandConfig(name, opts))
+}
+
+func logCommand(cmd *exec.Cmd, c *commandConfig) (func(), error) {
 	// Create pipes
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -26,27 +139,161 @@ func LogCommand(cmd *exec.Cmd, name string) (func(), error) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	// Helper to scan a pipe and log it
-	scan := func(pipe io.Reader, logFunc func(string, ...interface{}), level string) {
+	scan := func(pipe io.Reader, level LogLevel, stream string) {
 		defer wg.Done()
-		scanner := bufio.NewScanner(pipe)
-		for scanner.Scan() {
-			// Call your existing global logger here
-			// Assuming your logger is accessible as 'Log' or passed in.
-			// For this example, I'll assume you pass the log function.
-			logFunc("[%s] %s", name, scanner.Text())
+		err := readLines(pipe, c.maxLine, func(line string) {
+			c.logLine(level, line)
+		})
+		if err != nil && !errors.Is(err, os.ErrClosed) {
+			reportError("failed to read %s of '%s': %v", stream, c.name, err)
 		}
 	}
 
-	// Start scanning in background
-	// You can swap 'Infof' / 'Warnf' with whatever your actual logger uses
-	go scan(stdoutPipe, Infof, "INFO")
-	go scan(stderrPipe, Warnf, "WARN")
+	go scan(stdoutPipe, c.stdoutLevel, "stdout")
+	go scan(stderrPipe, c.stderrLevel, "stderr")
 
-	// Return a closure that waits for the logs to finish
-	waitForLogs := func() {
-		wg.Wait()
+	return wg.Wait, nil
+}
+
+// RunCommand starts cmd, logs its output as LogCommand does, and waits for
+// it to exit. Unless disabled with WithCommandStatus, it also logs the
+// start and the exit of the command. The error is that of cmd.Start or
+// cmd.Wait, so an *exec.ExitError can be recovered with errors.As.
+func RunCommand(cmd *exec.Cmd, name string, opts ...CommandOption) error {
+	c := newCommandConfig(name, opts)
+
+	wait, err := logCommand(cmd, c)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		// Start closes the pipes on failure, which ends the readers.
+		wait()
+		return fmt.Errorf("failed to start '%s': %w", name, err)
+	}
+	if c.status {
+		c.log(INFO, "Started", []Field{
+			{Key: "pid", Value: cmd.Process.Pid},
+			{Key: "args", Value: cmd.Args},
+		})
+	}
+
+	wait()
+	err = cmd.Wait()
+	if c.status {
+		c.logExit(cmd.ProcessState, time.Since(start))
+	}
+	if err != nil {
+		return fmt.Errorf("command '%s' failed: %w", name, err)
+	}
+	return nil
+}
+
+// logExit logs how the command exited: at INFO for a zero exit code and at
+// WARN otherwise.
+func (c *commandConfig) logExit(state *os.ProcessState, duration time.Duration) {
+	if state == nil {
+		return
+	}
+
+	signal := exitSignal(state)
+	fields := []Field{{Key: "exit_code", Value: state.ExitCode()}}
+	if signal != "" {
+		fields = append(fields, Field{Key: "signal", Value: signal})
+	}
+	fields = append(fields, Field{Key: "duration", Value: duration})
+
+	switch {
+	case state.Success():
+		c.log(INFO, "Exited", fields)
+	case signal != "":
+		c.log(WARN, "Exited on signal: "+signal, fields)
+	default:
+		c.log(WARN, fmt.Sprintf("Exited with code %d", state.ExitCode()), fields)
 	}
+}
+
+// log writes a line of the command, prefixed, with the command logger's
+// fields plus fields.
+func (c *commandConfig) log(level LogLevel, message string, fields []Field) {
+	logger := c.logger
+	if len(fields) > 0 {
+		all := make([]Field, len(logger.fields), len(logger.fields)+len(fields))
+		copy(all, logger.fields)
+		fields = append(all, fields...)
+	} else {
+		fields = logger.fields
+	}
+
+	entry := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: c.prefix + message,
+		Fields:  traceFields(logger.span, fields),
+		File:    c.file,
+		Line:    c.line,
+
+		template:    message,
+		pkg:         c.pkg,
+		prefixLen:   len(c.prefix),
+		prefixColor: c.color,
+	}
+	entry.levelOverride, entry.hasOverride = levelOverride(logger.name, c.pkg, c.file)
+	addSpanEvent(logger.span, entry)
+
+	if entry.Level == DEBUG && entry.threshold() < DEBUG {
+		return
+	}
+	dispatch(entry)
+}
 
-	return waitForLogs, nil
+// readLines calls emit with every line read from r, without the line
+// ending. Lines longer than max bytes are split; max <= 0 disables
+// splitting. It returns nil at EOF.
+func readLines(r io.Reader, max int, emit func(string)) error {
+	reader := bufio.NewReader(r)
+	var line []byte
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+
+		complete := err == nil
+		if complete {
+			line = line[:len(line)-1]
+			if n := len(line); n > 0 && line[n-1] == '\r' {
+				line = line[:n-1]
+			}
+		}
+
+		for max > 0 && len(line) > max {
+			cut := max
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = max
+			}
+			emit(string(line[:cut]))
+			line = line[cut:]
+		}
+
+		switch {
+		case complete:
+			emit(string(line))
+			line = line[:0]
+		case err == bufio.ErrBufferFull:
+			// The line continues in the next chunk.
+		default:
+			if len(line) > 0 {
+				emit(string(line))
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
 }
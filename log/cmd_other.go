@@ -0,0 +1,10 @@
+//go:build !unix
+
+package log
+
+import "os"
+
+// exitSignal returns "" on platforms where processes are not killed by signals.
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadLines(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+
+	for _, tt := range []struct {
+		name  string
+		input string
+		max   int
+		want  []string
+	}{
+		{"lines", "one\ntwo\n", 10, []string{"one", "two"}},
+		{"empty lines", "\n\nlast\n", 10, []string{"", "", "last"}},
+		{"crlf", "one\r\ntwo\r\n", 10, []string{"one", "two"}},
+		{"lone cr kept", "one\rtwo\n", 10, []string{"one\rtwo"}},
+		{"final line without newline", "one\ntwo", 10, []string{"one", "two"}},
+		{"no trailing empty line", "one\n", 10, []string{"one"}},
+		{"split at max", "abcdefgh\n", 3, []string{"abc", "def", "gh"}},
+		{"exactly max", "abc\n", 3, []string{"abc"}},
+		{"backtrack to rune start", "aé€b\n", 3, []string{"aé", "€", "b"}},
+		{"backtrack past several bytes", "ab€\n", 4, []string{"ab", "€"}},
+		{"no rune start within max", "\xa9\xa9\xa9\n", 2, []string{"\xa9\xa9", "\xa9"}},
+		{"unsplit when max is zero", "abcdefgh\n", 0, []string{"abcdefgh"}},
+		{"unsplit when max is negative", "abcdefgh", -1, []string{"abcdefgh"}},
+		{"long line unsplit", long + "\n", 0, []string{long}},
+		{"long line split", long + "\n", bufio.MaxScanTokenSize, []string{
+			long[:65536], long[65536:131072], long[131072:196608], long[196608:],
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if err := readLines(strings.NewReader(tt.input), tt.max, func(line string) {
+				got = append(got, line)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				if len(got) != len(tt.want) {
+					t.Fatalf("got %d lines, want %d", len(got), len(tt.want))
+				}
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLinesError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("one\npartial"), iotest.ErrReader(errRead))
+
+	var got []string
+	err := readLines(r, 0, func(line string) { got = append(got, line) })
+	if !errors.Is(err, errRead) {
+		t.Errorf("readLines() = %v, want %v", err, errRead)
+	}
+	if want := []string{"one", "partial"}; !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
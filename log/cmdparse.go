@@ -0,0 +1,258 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OutputFormat tells LogCommand how to read the lines a command writes.
+type OutputFormat int
+
+const (
+	// OutputText logs every line as its message.
+	OutputText OutputFormat = iota
+	// OutputJSON parses lines holding a JSON object.
+	OutputJSON
+	// OutputLogfmt parses lines made only of key=value pairs.
+	OutputLogfmt
+	// OutputAuto parses lines starting with "{" as JSON and others as logfmt.
+	OutputAuto
+)
+
+// WithOutputFormat parses the command's lines as structured logs. The
+// level of a parsed line ("level", "lvl" or "severity") replaces the level
+// of its stream and its message ("msg" or "message") becomes the message.
+// The other keys become fields, in order, including the command's own
+// timestamp: entries are timed when the line is read, so that they stay in
+// order with the rest of the log. Lines that do not parse are logged as text.
+//
+// Levels are matched by name, ignoring case: trace and debug are DEBUG,
+// info and notice INFO, warn and warning WARN, and anything more severe,
+// such as fatal or critical, ERROR. A command never makes the logger panic.
+// Numeric levels follow the pino and bunyan scale (30 is INFO, 40 WARN).
+func WithOutputFormat(format OutputFormat) CommandOption {
+	return func(c *commandConfig) {
+		c.format = format
+	}
+}
+
+// parsedLine is a structured line read from a command.
+type parsedLine struct {
+	level    LogLevel
+	hasLevel bool
+	message  string
+	fields   []Field
+}
+
+// parseLine parses line according to format and reports whether it succeeded.
+func parseLine(format OutputFormat, line string) (parsedLine, bool) {
+	switch format {
+	case OutputJSON:
+		return parseJSONLine(line)
+	case OutputLogfmt:
+		return parseLogfmtLine(line)
+	case OutputAuto:
+		if strings.HasPrefix(strings.TrimSpace(line), "{") {
+			return parseJSONLine(line)
+		}
+		return parseLogfmtLine(line)
+	default:
+		return parsedLine{}, false
+	}
+}
+
+func parseJSONLine(line string) (parsedLine, bool) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return parsedLine{}, false
+	}
+
+	var parsed parsedLine
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return parsedLine{}, false
+		}
+		key, ok := token.(string)
+		if !ok {
+			return parsedLine{}, false
+		}
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return parsedLine{}, false
+		}
+		parsed.add(key, childJSONValue(value))
+	}
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return parsedLine{}, false
+	}
+	// Anything after the object means the line was not a JSON log.
+	if strings.TrimSpace(line[decoder.InputOffset():]) != "" {
+		return parsedLine{}, false
+	}
+	return parsed, true
+}
+
+// childJSONValue converts the numbers of a decoded JSON value to int64 when they
+// are integers and to float64 otherwise.
+func childJSONValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i := range v {
+			v[i] = childJSONValue(v[i])
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = childJSONValue(v[key])
+		}
+	}
+	return value
+}
+
+// parseLogfmtLine parses a line of key=value pairs. Values are bare words or
+// double-quoted Go strings. Bare keys are not accepted, so that plain text
+// containing a key=value pair is not mistaken for logfmt.
+func parseLogfmtLine(line string) (parsedLine, bool) {
+	var parsed parsedLine
+	rest := strings.TrimSpace(line)
+	if rest == "" {
+		return parsedLine{}, false
+	}
+
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || strings.ContainsAny(rest[:eq], " \t\"") {
+			return parsedLine{}, false
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := quotedEnd(rest)
+			if end < 0 {
+				return parsedLine{}, false
+			}
+			unquoted, err := strconv.Unquote(rest[:end])
+			if err != nil {
+				return parsedLine{}, false
+			}
+			value, rest = unquoted, rest[end:]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				return parsedLine{}, false
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+			if strings.ContainsRune(value, '"') {
+				return parsedLine{}, false
+			}
+		}
+
+		parsed.add(key, value)
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return parsed, true
+}
+
+// quotedEnd returns the index just past the closing quote of the string
+// starting at s[0], or -1 if it is not closed.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// add records a key of the parsed line as its level or message, or as a
+// field.
+func (p *parsedLine) add(key string, value any) {
+	switch strings.ToLower(key) {
+	case "level", "lvl", "severity":
+		if level, ok := childLevel(value); ok && !p.hasLevel {
+			p.level, p.hasLevel = level, true
+			return
+		}
+	case "msg", "message":
+		if p.message == "" {
+			p.message = fmt.Sprint(value)
+			return
+		}
+	}
+	p.fields = append(p.fields, Field{Key: key, Value: value})
+}
+
+// childLevel maps the level of a command's line onto LogLevel, never PANIC.
+func childLevel(value any) (LogLevel, bool) {
+	switch v := value.(type) {
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "trace", "debug", "dbg":
+			return DEBUG, true
+		case "info", "information", "notice":
+			return INFO, true
+		case "warn", "warning":
+			return WARN, true
+		case "error", "err", "fatal", "critical", "crit", "alert", "emerg", "emergency", "panic", "dpanic":
+			return ERROR, true
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return childLevel(n)
+		}
+	case int64:
+		switch {
+		case v >= 50:
+			return ERROR, true
+		case v >= 40:
+			return WARN, true
+		case v >= 30:
+			return INFO, true
+		default:
+			return DEBUG, true
+		}
+	}
+	return 0, false
+}
+
+// logLine logs a line read from a command, parsing it if an output format
+// is set.
+func (c *commandConfig) logLine(level LogLevel, line string) {
+	if c.format == OutputText || !looksStructured(line) {
+		c.log(level, line, nil)
+		return
+	}
+
+	parsed, ok := parseLine(c.format, line)
+	if !ok {
+		c.log(level, line, nil)
+		return
+	}
+	if parsed.hasLevel {
+		level = parsed.level
+	}
+	c.log(level, parsed.message, parsed.fields)
+}
+
+// looksStructured is a cheap check that skips parsing lines that cannot be
+// JSON or logfmt.
+func looksStructured(line string) bool {
+	return strings.ContainsAny(line, "{=")
+}
@@ -0,0 +1,162 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseJSONLine(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		line string
+		want parsedLine
+		ok   bool
+	}{
+		{"object", `{"level":"warn","msg":"disk low","free":12,"ratio":0.5}`, parsedLine{
+			level: WARN, hasLevel: true, message: "disk low",
+			fields: []Field{{Key: "free", Value: int64(12)}, {Key: "ratio", Value: 0.5}},
+		}, true},
+		{"numeric pino level", `{"level":30,"msg":"listening"}`, parsedLine{
+			level: INFO, hasLevel: true, message: "listening",
+		}, true},
+		{"time kept as a field", `{"time":"2001-02-03T04:05:06Z","msg":"old"}`, parsedLine{
+			message: "old", fields: []Field{{Key: "time", Value: "2001-02-03T04:05:06Z"}},
+		}, true},
+		{"unknown level kept as a field", `{"level":"verbose","msg":"x"}`, parsedLine{
+			message: "x", fields: []Field{{Key: "level", Value: "verbose"}},
+		}, true},
+		{"second message kept as a field", `{"msg":"first","message":"second"}`, parsedLine{
+			message: "first", fields: []Field{{Key: "message", Value: "second"}},
+		}, true},
+		{"nested numbers", `{"req":{"status":200,"ids":[1,2.5]}}`, parsedLine{
+			fields: []Field{{Key: "req", Value: map[string]any{"status": int64(200), "ids": []any{int64(1), 2.5}}}},
+		}, true},
+		{"trailing whitespace", "{\"msg\":\"x\"}  \t", parsedLine{message: "x"}, true},
+		{"trailing garbage", `{"msg":"x"} and more`, parsedLine{}, false},
+		{"two objects", `{"msg":"x"}{"msg":"y"}`, parsedLine{}, false},
+		{"truncated", `{"msg":"x"`, parsedLine{}, false},
+		{"array", `["msg","x"]`, parsedLine{}, false},
+		{"text", `{not json}`, parsedLine{}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseJSONLine(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseJSONLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLogfmtLine(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		line string
+		want parsedLine
+		ok   bool
+	}{
+		{"pairs", `level=error msg="query failed" table=users`, parsedLine{
+			level: ERROR, hasLevel: true, message: "query failed",
+			fields: []Field{{Key: "table", Value: "users"}},
+		}, true},
+		{"escaped quotes", `msg="say \"hi\"" n=1`, parsedLine{
+			message: `say "hi"`, fields: []Field{{Key: "n", Value: "1"}},
+		}, true},
+		{"numeric level", "lvl=40 msg=slow", parsedLine{level: WARN, hasLevel: true, message: "slow"}, true},
+		{"ts kept as a field", "ts=2001-02-03T04:05:06Z msg=old", parsedLine{
+			message: "old", fields: []Field{{Key: "ts", Value: "2001-02-03T04:05:06Z"}},
+		}, true},
+		{"empty value", "msg= user=ada", parsedLine{fields: []Field{{Key: "user", Value: "ada"}}}, true},
+		{"a=b in plain text", "connecting to db host=localhost", parsedLine{}, false},
+		{"text after a=b", "a=b then text", parsedLine{}, false},
+		{"bare key", "a=b flag", parsedLine{}, false},
+		{"missing key", "=value", parsedLine{}, false},
+		{"unterminated quote", `msg="oops`, parsedLine{}, false},
+		{"text after quote", `msg="x"y`, parsedLine{}, false},
+		{"quote in bare value", `a=b"c`, parsedLine{}, false},
+		{"empty", "  ", parsedLine{}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLogfmtLine(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseLogfmtLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLogfmtLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChildLevel(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		want  LogLevel
+		ok    bool
+	}{
+		{"trace", DEBUG, true},
+		{"debug", DEBUG, true},
+		{"INFO", INFO, true},
+		{"notice", INFO, true},
+		{" Warning ", WARN, true},
+		{"error", ERROR, true},
+		{"fatal", ERROR, true},
+		{"critical", ERROR, true},
+		{"panic", ERROR, true},
+		{"PANIC", ERROR, true},
+		{"dpanic", ERROR, true},
+		{int64(10), DEBUG, true},
+		{int64(20), DEBUG, true},
+		{int64(30), INFO, true},
+		{int64(40), WARN, true},
+		{int64(50), ERROR, true},
+		{int64(60), ERROR, true},
+		{"30", INFO, true},
+		{"60", ERROR, true},
+		{"verbose", 0, false},
+		{30.5, 0, false},
+		{true, 0, false},
+		{nil, 0, false},
+	} {
+		t.Run(fmt.Sprintf("%T %v", tt.value, tt.value), func(t *testing.T) {
+			got, ok := childLevel(tt.value)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("childLevel(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+			if got == PANIC && ok {
+				t.Errorf("childLevel(%#v) = PANIC", tt.value)
+			}
+		})
+	}
+}
+
+func TestCommandLineKeepsReadTime(t *testing.T) {
+	sink := capture(t)
+	c := newCommandConfig("child", []CommandOption{WithOutputFormat(OutputAuto)})
+
+	before := time.Now()
+	c.logLine(INFO, `{"level":"warn","time":"2001-02-03T04:05:06Z","msg":"from json"}`)
+	c.logLine(INFO, "ts=2001-02-03T04:05:06Z msg=logfmt")
+	after := time.Now()
+
+	entries := sink.all()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	for i, key := range []string{"time", "ts"} {
+		entry := entries[i]
+		if entry.Time.Before(before) || entry.Time.After(after) {
+			t.Errorf("entry %d time = %v, want the time the line was read", i, entry.Time)
+		}
+		if value, _ := fieldValue(entry, key); value != "2001-02-03T04:05:06Z" {
+			t.Errorf("entry %d field %s = %v, want the command's timestamp", i, key, value)
+		}
+	}
+	if entries[0].Level != WARN || entries[0].Message != "[child] from json" {
+		t.Errorf("entry = %v %q", entries[0].Level, entries[0].Message)
+	}
+}
@@ -0,0 +1,251 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Theme holds the ANSI escape sequences used to colour console output.
+// An empty sequence leaves that part uncoloured.
+type Theme struct {
+	Timestamp string // also used for the caller at DEBUG level
+	Levels    map[LogLevel]string
+	FieldKey  string
+	Counter   string // the "(Nx)" suffix of collapsed repeats
+}
+
+// DefaultTheme is the theme used by ConsoleEncoder.
+var DefaultTheme = Theme{
+	Timestamp: colorDarkGrey,
+	Levels: map[LogLevel]string{
+		PANIC:   colorRed,
+		ERROR:   colorRed,
+		WARN:    colorYellow,
+		INFO:    colorBlue,
+		DEBUG:   colorYellow,
+		REQUEST: colorDarkGrey,
+	},
+	FieldKey: colorDarkGrey,
+	Counter:  colorYellow,
+}
+
+func (t *Theme) timestamp() string {
+	if t == nil {
+		return ""
+	}
+	return t.Timestamp
+}
+
+func (t *Theme) level(level LogLevel) string {
+	if t == nil {
+		return ""
+	}
+	return t.Levels[level]
+}
+
+func (t *Theme) fieldKey() string {
+	if t == nil {
+		return ""
+	}
+	return t.FieldKey
+}
+
+func (t *Theme) counter() string {
+	if t == nil {
+		return ""
+	}
+	return t.Counter
+}
+
+// paint wraps s in color, unless color is empty.
+func paint(color, s string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+type ColorMode int
+
+const (
+	// ColorAuto colours output written to a terminal, honouring the
+	// FORCE_COLOR, NO_COLOR and TERM=dumb environment conventions.
+	ColorAuto ColorMode = iota
+	// ColorAlways colours output regardless of the writer or environment.
+	ColorAlways
+	// ColorNever writes plain text.
+	ColorNever
+)
+
+// ConsoleEncoder produces the coloured console line, including the caller
+// when the global level is DEBUG.
+var ConsoleEncoder Encoder = NewConsoleEncoder(&DefaultTheme)
+
+// PlainConsoleEncoder produces the console line without any escape sequences.
+var PlainConsoleEncoder Encoder = NewConsoleEncoder(nil)
+
+// NewConsoleEncoder returns a console encoder using theme. A nil theme
+// produces plain text.
+func NewConsoleEncoder(theme *Theme) Encoder {
+	return consoleEncoder{theme: theme}
+}
+
+type consoleEncoder struct {
+	theme *Theme
+}
+
+func (e consoleEncoder) Encode(entry *Entry) string {
+	if GetLevel() == DEBUG {
+		return formatLog(STDOUT_DEBUG, entry, e.theme)
+	}
+	return formatLog(STDOUT, entry, e.theme)
+}
+
+// ConsoleOption configures a ConsoleSink.
+type ConsoleOption func(*consoleOptions)
+
+type consoleOptions struct {
+	mode  ColorMode
+	theme Theme
+}
+
+// WithColorMode sets whether the console is coloured. Defaults to ColorAuto.
+func WithColorMode(mode ColorMode) ConsoleOption {
+	return func(o *consoleOptions) {
+		o.mode = mode
+	}
+}
+
+// WithTheme sets the colours used when the console is coloured.
+// Defaults to DefaultTheme.
+func WithTheme(theme Theme) ConsoleOption {
+	return func(o *consoleOptions) {
+		o.theme = theme
+	}
+}
+
+// ConsoleSink writes lines to a console. On a terminal, consecutive repeats
+// of the same message are collapsed into a single line with a counter.
+type ConsoleSink struct {
+	w        io.Writer
+	terminal bool
+	theme    *Theme // nil when the console is not coloured
+	mu       sync.Mutex
+	dedup    consoleDedup
+}
+
+// NewConsoleSink returns a console sink writing to w, typically os.Stdout or
+// os.Stderr. Register it with the encoder returned by its Encoder method so
+// entries are coloured according to the sink's options.
+func NewConsoleSink(w io.Writer, opts ...ConsoleOption) *ConsoleSink {
+	options := consoleOptions{mode: ColorAuto, theme: DefaultTheme}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	terminal := isTerminal(w)
+	s := &ConsoleSink{
+		w:        w,
+		terminal: terminal && os.Getenv("TERM") != "dumb",
+	}
+	if colorEnabled(options.mode, terminal) {
+		s.theme = &options.theme
+	}
+	return s
+}
+
+// Encoder returns the console encoder matching the sink's colour settings.
+func (s *ConsoleSink) Encoder() Encoder {
+	return NewConsoleEncoder(s.theme)
+}
+
+// ConfigureConsole replaces the built-in "console" sink on os.Stdout with one
+// configured by opts. It follows the global level.
+func ConfigureConsole(opts ...ConsoleOption) {
+	console := NewConsoleSink(os.Stdout, opts...)
+	AddSink("console", console, WithEncoder(console.Encoder()))
+}
+
+// colorEnabled decides whether to colour output. FORCE_COLOR takes
+// precedence over NO_COLOR, which takes precedence over TERM=dumb.
+// See https://no-color.org and https://force-color.org.
+func colorEnabled(mode ColorMode, terminal bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if force, ok := os.LookupEnv("FORCE_COLOR"); ok {
+		switch strings.ToLower(force) {
+		case "0", "false", "no":
+			return false
+		default:
+			return true
+		}
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return terminal
+}
+
+// reportError writes a diagnostic about logging itself to stderr, bypassing
+// the sinks, which may be what failed. It is coloured like an ERROR entry
+// when stderr is a terminal that accepts colour.
+func reportError(format string, args ...any) {
+	message := "ERROR: " + fmt.Sprintf(format, args...)
+	if colorEnabled(ColorAuto, isTerminal(os.Stderr)) {
+		message = colorRed + " " + message + colorReset
+	}
+	fmt.Fprintln(os.Stderr, message)
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (s *ConsoleSink) Write(entry *Entry, line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Collapsing repeats moves the cursor, which only works on a terminal.
+	if !s.terminal {
+		_, err := fmt.Fprintln(s.w, line)
+		return err
+	}
+
+	if count, repeated := s.dedup.repeat(entry.Level, entry.Message+formatFields(entry.Fields, "")); repeated {
+		_, err := fmt.Fprint(s.w,
+			"\033[F", // Move cursor up one line
+			"\r",     // Move to the beginning of that line
+			"\033[K", // Clear that line
+			line, " ", paint(s.theme.counter(), fmt.Sprintf("(%dx)", count)), "\n",
+		)
+		return err
+	}
+
+	_, err := fmt.Fprintln(s.w, line)
+	return err
+}
+
+func (s *ConsoleSink) Flush() error {
+	return nil
+}
+
+func (s *ConsoleSink) Close() error {
+	return nil
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReportError(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		forceColor string
+		want       string
+	}{
+		{"not a terminal", "", "ERROR: sink x failed: boom\n"},
+		{"forced colour", "1", colorRed + " ERROR: sink x failed: boom" + colorReset + "\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.forceColor == "" {
+				t.Setenv("FORCE_COLOR", "") // restored after the test
+				os.Unsetenv("FORCE_COLOR")
+			} else {
+				t.Setenv("FORCE_COLOR", tt.forceColor)
+			}
+
+			stderr := captureStderr(t)
+			reportError("sink %s failed: %v", "x", "boom")
+
+			if got := stderr(); got != tt.want {
+				t.Errorf("stderr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
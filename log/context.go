@@ -0,0 +1,111 @@
+package log
+
+import (
+	"context"
+)
+
+type contextKey int
+
+const (
+	loggerContextKey contextKey = iota
+	requestIDContextKey
+	clientIPContextKey
+)
+
+// RequestIDField is the field request IDs are logged under.
+const RequestIDField = "request_id"
+
+// WithLogger returns a copy of ctx carrying logger, for FromContext to return.
+func WithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// FromContext returns the logger stored in ctx, or Default() if there is
+// none. If ctx carries an OpenTelemetry span, the logger adds its trace and
+// span IDs to every entry, as with Logger.WithContext.
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return std
+	}
+	logger, ok := ctx.Value(loggerContextKey).(*Logger)
+	if !ok {
+		logger = std
+	}
+	return logger.WithContext(ctx)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID and a logger
+// that adds it to every entry.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDContextKey, id)
+	return WithLogger(ctx, FromContext(ctx).With(RequestIDField, id))
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// ClientIPFromContext returns the client address LogRequest determined for
+// the request, honouring its trusted proxies, or "".
+func ClientIPFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ip, _ := ctx.Value(clientIPContextKey).(string)
+	return ip
+}
+
+// The ...Context functions log through the logger stored in ctx.
+
+func InfoContext(ctx context.Context, args ...any) {
+	FromContext(ctx).Info(args...)
+}
+
+func InfofContext(ctx context.Context, format string, args ...any) {
+	FromContext(ctx).Infof(format, args...)
+}
+
+func RequestContext(ctx context.Context, args ...any) {
+	FromContext(ctx).Request(args...)
+}
+
+func RequestfContext(ctx context.Context, format string, args ...any) {
+	FromContext(ctx).Requestf(format, args...)
+}
+
+func WarnContext(ctx context.Context, args ...any) {
+	FromContext(ctx).Warn(args...)
+}
+
+func WarnfContext(ctx context.Context, format string, args ...any) {
+	FromContext(ctx).Warnf(format, args...)
+}
+
+func ErrorContext(ctx context.Context, args ...any) {
+	FromContext(ctx).Error(args...)
+}
+
+func ErrorfContext(ctx context.Context, format string, args ...any) {
+	FromContext(ctx).Errorf(format, args...)
+}
+
+func DebugContext(ctx context.Context, args ...any) {
+	FromContext(ctx).Debug(args...)
+}
+
+func DebugfContext(ctx context.Context, format string, args ...any) {
+	FromContext(ctx).Debugf(format, args...)
+}
+
+func PanicContext(ctx context.Context, args ...any) {
+	FromContext(ctx).Panic(args...)
+}
+
+func PanicfContext(ctx context.Context, format string, args ...any) {
+	FromContext(ctx).Panicf(format, args...)
+}
@@ -0,0 +1,48 @@
+package log
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(nil); got != Default() {
+		t.Error("FromContext(nil) is not the default logger")
+	}
+	if got := FromContext(context.Background()); got != Default() {
+		t.Error("FromContext without a logger is not the default logger")
+	}
+
+	logger := New("user", "ada")
+	ctx := WithLogger(context.Background(), logger)
+	if got := FromContext(ctx); got != logger {
+		t.Error("FromContext did not return the stored logger")
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	if got := RequestIDFromContext(nil); got != "" {
+		t.Errorf("RequestIDFromContext(nil) = %q", got)
+	}
+	if got := RequestIDFromContext(context.Background()); got != "" {
+		t.Errorf("RequestIDFromContext without an ID = %q", got)
+	}
+
+	// The request ID is added to the logger already stored in the context.
+	ctx := WithLogger(context.Background(), New("user", "ada"))
+	ctx = WithRequestID(ctx, "r1")
+	if got := RequestIDFromContext(ctx); got != "r1" {
+		t.Errorf("RequestIDFromContext() = %q, want %q", got, "r1")
+	}
+	want := []Field{{Key: "user", Value: "ada"}, {Key: RequestIDField, Value: "r1"}}
+	if got := FromContext(ctx).Fields(); !slices.Equal(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+
+	sink := capture(t)
+	InfoContext(ctx, "with id")
+	if got, _ := fieldValue(sink.all()[0], RequestIDField); got != "r1" {
+		t.Errorf("request_id = %v, want %q", got, "r1")
+	}
+}
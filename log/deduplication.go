@@ -1,40 +1,225 @@
 package log
 
 import (
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
-var (
+// consoleDedup tracks the last line written to a console so consecutive
+// repeats can be collapsed into one line with a counter.
+type consoleDedup struct {
 	latestLogLevel   LogLevel
 	latestLogMessage string
 	latestCounter    int
 
-	consoleDedupMu sync.Mutex
-)
+	mu sync.Mutex
+}
 
-func dedupConsoleLog(
-	dest Destination,
+// repeat records a message and reports whether it repeats the previous one,
+// along with how many times it has now been seen in a row.
+func (d *consoleDedup) repeat(
 	level LogLevel,
 	message string,
-) bool {
-	consoleDedupMu.Lock()
-	defer consoleDedupMu.Unlock()
+) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	if dest == FILE {
-		return false
+	if level != DEBUG &&
+		level == d.latestLogLevel &&
+		message == d.latestLogMessage {
+
+		d.latestCounter++
+		return d.latestCounter, true
 	}
 
-	if level != DEBUG &&
-		level == latestLogLevel &&
-		message == latestLogMessage {
+	d.latestLogLevel = level
+	d.latestLogMessage = message
+	d.latestCounter = 1
 
-		latestCounter++
+	return d.latestCounter, false
+}
+
+// Deduplication configures the windowed deduplication applied to every sink.
+// An entry with the same level, message and fields as one first seen less
+// than Window ago is suppressed; once the window has passed, a single
+// summary entry reports how often it was repeated. PANIC entries are never
+// suppressed. The zero value disables it.
+type Deduplication struct {
+	Window time.Duration
+	// MaxKeys bounds the number of distinct messages tracked. Defaults to 1000.
+	MaxKeys int
+}
+
+type dedupRecord struct {
+	entry     *Entry
+	firstSeen time.Time
+	lastSeen  time.Time
+	repeats   int
+}
+
+var (
+	deduplication Deduplication
+	dedupMu       sync.Mutex
+	dedupRecords  = map[string]*dedupRecord{}
+	dedupStop     chan struct{}
+	dedupDone     chan struct{}
+)
+
+// SetDeduplication replaces the deduplication configuration. Pending
+// repeats are summarized before the new configuration takes effect.
+func SetDeduplication(config Deduplication) {
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = 1000
+	}
+
+	dedupMu.Lock()
+	stop, done := dedupStop, dedupDone
+	dedupStop, dedupDone = nil, nil
+	dedupMu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	dedupMu.Lock()
+	defer dedupMu.Unlock()
+
+	deduplication = config
+	dedupRecords = map[string]*dedupRecord{}
+
+	if config.Window <= 0 {
+		return
+	}
+
+	dedupStop = make(chan struct{})
+	dedupDone = make(chan struct{})
+	go expireDuplicates(config.Window/2, dedupStop, dedupDone)
+}
+
+// dedupEntry reports whether the entry should be written.
+func dedupEntry(entry *Entry) bool {
+	if entry.Level == PANIC {
 		return true
 	}
 
-	latestLogLevel = level
-	latestLogMessage = message
-	latestCounter = 1
+	dedupMu.Lock()
+	if deduplication.Window <= 0 {
+		dedupMu.Unlock()
+		return true
+	}
 
-	return false
+	key := entry.Level.String() + "\x00" + entry.Message + formatFields(entry.Fields, "")
+	record := dedupRecords[key]
+	if record != nil && entry.Time.Sub(record.firstSeen) < deduplication.Window {
+		record.repeats++
+		record.lastSeen = entry.Time
+		dedupMu.Unlock()
+		return false
+	}
+
+	var summaries []*Entry
+	if record != nil {
+		summaries = append(summaries, record.summary())
+	} else if len(dedupRecords) >= deduplication.MaxKeys {
+		summaries = append(summaries, evictOldestDuplicate())
+	}
+	dedupRecords[key] = &dedupRecord{entry: entry, firstSeen: entry.Time, lastSeen: entry.Time}
+	dedupMu.Unlock()
+
+	for _, summary := range summaries {
+		if summary != nil {
+			deliver(summary)
+		}
+	}
+	return true
+}
+
+// evictOldestDuplicate forgets the record seen longest ago and returns its
+// summary, if any. Callers must hold dedupMu.
+func evictOldestDuplicate() *Entry {
+	var oldestKey string
+	var oldest *dedupRecord
+	for key, record := range dedupRecords {
+		if oldest == nil || record.lastSeen.Before(oldest.lastSeen) {
+			oldestKey, oldest = key, record
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+	delete(dedupRecords, oldestKey)
+	return oldest.summary()
+}
+
+// summary returns the entry reporting the suppressed repeats, or nil if there were none.
+func (r *dedupRecord) summary() *Entry {
+	if r.repeats == 0 {
+		return nil
+	}
+
+	fields := make([]Field, len(r.entry.Fields), len(r.entry.Fields)+1)
+	copy(fields, r.entry.Fields)
+	fields = append(fields, Field{Key: "repeated", Value: r.repeats})
+
+	return &Entry{
+		Time:  r.lastSeen,
+		Level: r.entry.Level,
+		Message: fmt.Sprintf(
+			"Message repeated %d times over %s: %s",
+			r.repeats, r.lastSeen.Sub(r.firstSeen).Round(time.Millisecond), r.entry.Message,
+		),
+		Fields: fields,
+		File:   r.entry.File,
+		Line:   r.entry.Line,
+
+		pkg:           r.entry.pkg,
+		levelOverride: r.entry.levelOverride,
+		hasOverride:   r.entry.hasOverride,
+	}
+}
+
+func expireDuplicates(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			writeDuplicateSummaries(false)
+		case <-stop:
+			writeDuplicateSummaries(true)
+			return
+		}
+	}
+}
+
+// writeDuplicateSummaries forgets records whose window has passed, or all of
+// them if all is set, and writes their summaries.
+func writeDuplicateSummaries(all bool) {
+	now := time.Now()
+
+	dedupMu.Lock()
+	var summaries []*Entry
+	for key, record := range dedupRecords {
+		if !all && now.Sub(record.firstSeen) < deduplication.Window {
+			continue
+		}
+		delete(dedupRecords, key)
+		if summary := record.summary(); summary != nil {
+			summaries = append(summaries, summary)
+		}
+	}
+	dedupMu.Unlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Time.Before(summaries[j].Time)
+	})
+	for _, summary := range summaries {
+		deliver(summary)
+	}
 }
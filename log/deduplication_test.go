@@ -0,0 +1,137 @@
+package log
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setDeduplication applies config for the rest of the test.
+func setDeduplication(t *testing.T, config Deduplication) {
+	t.Helper()
+	SetDeduplication(config)
+	t.Cleanup(func() { SetDeduplication(Deduplication{}) })
+}
+
+func TestDeduplicationSuppressesInterleavedRepeats(t *testing.T) {
+	sink := capture(t)
+	setDeduplication(t, Deduplication{Window: time.Hour})
+
+	for _, message := range []string{"dedup a", "dedup b", "dedup a", "dedup b", "dedup a"} {
+		Info(message)
+	}
+	Warn("dedup a") // another level is another message
+
+	want := []string{"dedup a", "dedup b", "dedup a"}
+	if got := sink.messages(); !slices.Equal(got, want) {
+		t.Errorf("written %q, want %q", got, want)
+	}
+}
+
+func TestDeduplicationSummaryAfterWindow(t *testing.T) {
+	resetFileLogging(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := InitFileLogging(path); err != nil {
+		t.Fatal(err)
+	}
+	sink := capture(t)
+	const window = 100 * time.Millisecond
+	setDeduplication(t, Deduplication{Window: window})
+
+	for i := 0; i < 3; i++ {
+		Info("dedup window")
+	}
+
+	summary := regexp.MustCompile(`^Message repeated 2 times over \S+: dedup window$`)
+	summaries := func() int {
+		n := 0
+		for _, message := range sink.messages() {
+			if summary.MatchString(message) {
+				n++
+			}
+		}
+		return n
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for summaries() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(2 * window)
+
+	if n := summaries(); n != 1 {
+		t.Fatalf("%d summaries written, want 1: %q", n, sink.messages())
+	}
+	entries := sink.all()
+	last := entries[len(entries)-1]
+	if n, _ := fieldValue(last, "repeated"); n != 2 {
+		t.Errorf("repeated = %v, want 2", n)
+	}
+
+	CloseFile()
+	var inFile int
+	for _, line := range strings.Split(readFile(t, path), "\n") {
+		if strings.Contains(line, "Message repeated 2 times") && strings.Contains(line, "dedup window") {
+			inFile++
+		}
+	}
+	if inFile != 1 {
+		t.Errorf("file holds the summary %d times, want 1", inFile)
+	}
+}
+
+func TestDeduplicationMaxKeysEviction(t *testing.T) {
+	sink := capture(t)
+	setDeduplication(t, Deduplication{Window: time.Hour, MaxKeys: 2})
+
+	Info("dedup key 1")
+	Info("dedup key 1")
+	Info("dedup key 2")
+	Info("dedup key 3") // evicts key 1, the least recently seen
+
+	got := sink.messages()
+	if len(got) != 4 {
+		t.Fatalf("written %q, want 4 entries", got)
+	}
+	if !strings.HasPrefix(got[2], "Message repeated 1 times over ") || !strings.HasSuffix(got[2], ": dedup key 1") {
+		t.Errorf("eviction summary = %q", got[2])
+	}
+	if got[3] != "dedup key 3" {
+		t.Errorf("last entry = %q, want %q", got[3], "dedup key 3")
+	}
+}
+
+func TestDeduplicationNeverSuppressesPanic(t *testing.T) {
+	setDeduplication(t, Deduplication{Window: time.Hour})
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !dedupEntry(&Entry{Time: now, Level: PANIC, Message: "dedup panic"}) {
+			t.Fatalf("PANIC entry %d suppressed", i)
+		}
+	}
+}
+
+func TestDeduplicationSummaryKeepsLevelOverride(t *testing.T) {
+	// A sink following the global level, which is above DEBUG.
+	sink := &captureSink{}
+	AddSink("dedup-global", sink)
+	t.Cleanup(func() { RemoveSink("dedup-global") })
+	setDeduplication(t, Deduplication{Window: time.Hour})
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		entry := &Entry{Time: now, Level: DEBUG, Message: "dedup debug", levelOverride: DEBUG, hasOverride: true}
+		if dedupEntry(entry) {
+			deliver(entry)
+		}
+	}
+	SetDeduplication(Deduplication{}) // writes the pending summary
+
+	want := []string{"dedup debug", "Message repeated 2 times over 0s: dedup debug"}
+	if got := sink.messages(); !slices.Equal(got, want) {
+		t.Errorf("written %q, want %q", got, want)
+	}
+}
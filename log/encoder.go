@@ -0,0 +1,132 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoder turns an entry into a single line of output, without the trailing newline.
+type Encoder interface {
+	Encode(entry *Entry) string
+}
+
+var (
+	// TextEncoder produces the classic "timestamp file:line LEVEL: message" line.
+	TextEncoder Encoder = textEncoder{}
+	// JSONEncoder produces one JSON object per line.
+	JSONEncoder Encoder = jsonEncoder{}
+	// LogfmtEncoder produces space separated key=value pairs.
+	LogfmtEncoder Encoder = logfmtEncoder{}
+)
+
+// Keys used by the structured encoders. Fields that collide with one of them
+// are written with a "fields." prefix so they cannot overwrite the entry itself.
+const (
+	timeKey   = "time"
+	levelKey  = "level"
+	callerKey = "caller"
+	msgKey    = "msg"
+)
+
+func fieldKey(key string) string {
+	switch key {
+	case timeKey, levelKey, callerKey, msgKey:
+		return "fields." + key
+	}
+	return key
+}
+
+func formatCaller(entry *Entry) string {
+	return entry.File + ":" + strconv.Itoa(entry.Line)
+}
+
+type textEncoder struct{}
+
+func (textEncoder) Encode(entry *Entry) string {
+	return formatLog(FILE, entry, nil)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(entry *Entry) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	writeJSONPair(&b, timeKey, entry.Time.UTC().Format(time.RFC3339Nano))
+	b.WriteByte(',')
+	writeJSONPair(&b, levelKey, entry.Level.String())
+	b.WriteByte(',')
+	writeJSONPair(&b, callerKey, formatCaller(entry))
+	b.WriteByte(',')
+	writeJSONPair(&b, msgKey, entry.Message)
+	for _, field := range entry.Fields {
+		b.WriteByte(',')
+		writeJSONPair(&b, fieldKey(field.Key), field.Value)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func writeJSONPair(b *strings.Builder, key string, value any) {
+	b.Write(jsonValue(key))
+	b.WriteByte(':')
+	b.Write(jsonValue(value))
+}
+
+// jsonValue marshals a field value, falling back to its fmt representation
+// for values encoding/json cannot handle. Errors are rendered via Error().
+func jsonValue(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		data, _ := json.Marshal(v)
+		return data
+	case error:
+		data, _ := json.Marshal(v.Error())
+		return data
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return data
+}
+
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) Encode(entry *Entry) string {
+	var b strings.Builder
+	b.WriteString(timeKey + "=")
+	b.WriteString(entry.Time.UTC().Format(time.RFC3339Nano))
+	b.WriteString(" " + levelKey + "=")
+	b.WriteString(entry.Level.String())
+	b.WriteString(" " + callerKey + "=")
+	b.WriteString(formatFieldValue(formatCaller(entry)))
+	b.WriteString(" " + msgKey + "=")
+	b.WriteString(formatFieldValue(entry.Message))
+	for _, field := range entry.Fields {
+		b.WriteByte(' ')
+		b.WriteString(logfmtKey(fieldKey(field.Key)))
+		b.WriteByte('=')
+		b.WriteString(formatFieldValue(field.Value))
+	}
+	return b.String()
+}
+
+// logfmtKey replaces the characters that would end or split a logfmt key,
+// whitespace and other control characters, '=' and '"', with '_'. An empty
+// key is written as "_".
+func logfmtKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if key == "" {
+		return "_"
+	}
+	return key
+}
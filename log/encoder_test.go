@@ -0,0 +1,120 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func encoderTestEntry(fields ...Field) *Entry {
+	return &Entry{
+		Time:    time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC),
+		Level:   WARN,
+		Message: "line one\nline \"two\" = 2",
+		Fields:  fields,
+		File:    "main.go",
+		Line:    42,
+	}
+}
+
+func TestJSONEncoderRoundTrip(t *testing.T) {
+	entry := encoderTestEntry(
+		Field{Key: "a\x01b", Value: "v\x07\x1b"},
+		Field{Key: "user name", Value: "ada"},
+		Field{Key: `quote"key`, Value: `back\slash`},
+		Field{Key: "msg", Value: "shadowed"},
+		Field{Key: "err", Value: errors.New("boom")},
+		Field{Key: "n", Value: 3},
+		Field{Key: "html", Value: "<b>&</b>"},
+		Field{Key: "ch", Value: make(chan int)},
+	)
+	line := JSONEncoder.Encode(entry)
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", line, err)
+	}
+	want := map[string]any{
+		timeKey:      "2024-05-06T07:08:09.123Z",
+		levelKey:     "WARN",
+		callerKey:    "main.go:42",
+		msgKey:       entry.Message,
+		"a\x01b":     "v\x07\x1b",
+		"user name":  "ada",
+		`quote"key`:  `back\slash`,
+		"fields.msg": "shadowed",
+		"err":        "boom",
+		"n":          float64(3),
+		"html":       "<b>&</b>",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%q = %#v, want %#v", key, got[key], value)
+		}
+	}
+	if _, ok := got["ch"].(string); !ok {
+		t.Errorf("ch = %#v, want its fmt representation", got["ch"])
+	}
+	if len(got) != len(want)+1 {
+		t.Errorf("decoded %d keys, want %d: %v", len(got), len(want)+1, got)
+	}
+}
+
+func TestLogfmtEncoderRoundTrip(t *testing.T) {
+	entry := encoderTestEntry(
+		Field{Key: "user name", Value: "ada lovelace"},
+		Field{Key: "a=b", Value: "x=y"},
+		Field{Key: `q"k`, Value: `say "hi"`},
+		Field{Key: "tab\tnew\nline", Value: "multi\nline"},
+		Field{Key: "", Value: ""},
+		Field{Key: "msg", Value: "shadowed"},
+		Field{Key: "ctl\x01", Value: "bell\x07"},
+		Field{Key: "n", Value: 3},
+	)
+	line := LogfmtEncoder.Encode(entry)
+
+	parsed, ok := parseLogfmtLine(line)
+	if !ok {
+		t.Fatalf("invalid logfmt: %s", line)
+	}
+	if parsed.message != entry.Message {
+		t.Errorf("msg = %q, want %q", parsed.message, entry.Message)
+	}
+	if !parsed.hasLevel || parsed.level != WARN {
+		t.Errorf("level = %v, want %v", parsed.level, WARN)
+	}
+	want := []Field{
+		{Key: timeKey, Value: "2024-05-06T07:08:09.123Z"},
+		{Key: callerKey, Value: "main.go:42"},
+		{Key: "user_name", Value: "ada lovelace"},
+		{Key: "a_b", Value: "x=y"},
+		{Key: "q_k", Value: `say "hi"`},
+		{Key: "tab_new_line", Value: "multi\nline"},
+		{Key: "_", Value: ""},
+		{Key: "fields.msg", Value: "shadowed"},
+		{Key: "ctl_", Value: "bell\x07"},
+		{Key: "n", Value: "3"},
+	}
+	if !slices.Equal(parsed.fields, want) {
+		t.Errorf("fields = %q\nwant %q", parsed.fields, want)
+	}
+}
+
+func TestLogfmtKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"user":       "user",
+		"user.id":    "user.id",
+		"ключ":       "ключ",
+		"user name":  "user_name",
+		"a=b":        "a_b",
+		`a"b`:        "a_b",
+		"a\x7fb\x00": "a_b_",
+		"":           "_",
+	} {
+		if got := logfmtKey(key); got != want {
+			t.Errorf("logfmtKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Fields added by WithError and SetStackTraces.
+const (
+	ErrorField  = "error"
+	CausesField = "causes"
+	StackField  = "stack"
+)
+
+// maxCauseDepth bounds how deep WithError walks an error tree.
+const maxCauseDepth = 32
+
+var stackTraces atomic.Bool
+
+// SetStackTraces controls whether ERROR and PANIC entries carry the stack of
+// the goroutine that logged them, in a "stack" field. Entries that already
+// have a "stack" field, such as those logged by Recover, are left alone.
+func SetStackTraces(enabled bool) {
+	stackTraces.Store(enabled)
+}
+
+// WithError returns a child logger carrying err under "error" and, when err
+// wraps other errors, every cause in the errors.Unwrap and errors.Join tree
+// under "causes". A nil err returns l.
+func (l *Logger) WithError(err error) *Logger {
+	if err == nil {
+		return l
+	}
+
+	keyvals := []any{Field{Key: ErrorField, Value: err.Error()}}
+	if causes := errorCauses(err); len(causes) > 1 {
+		keyvals = append(keyvals, Field{Key: CausesField, Value: causes})
+	}
+	return l.With(keyvals...)
+}
+
+// WithError returns a logger carrying err, as Logger.WithError does.
+func WithError(err error) *Logger {
+	return std.WithError(err)
+}
+
+// Causes lists an error and the errors it wraps, depth first, one per
+// line indented by depth, each as its type and message. Messages spanning
+// several lines, such as those of errors.Join, are joined with "; ". It
+// prints as the indented lines and encodes to JSON as an array.
+type Causes []string
+
+func (c Causes) String() string {
+	return strings.Join(c, "\n")
+}
+
+func errorCauses(err error) Causes {
+	var causes Causes
+	var walk func(err error, depth int)
+	walk = func(err error, depth int) {
+		if err == nil || depth >= maxCauseDepth {
+			return
+		}
+
+		message := strings.ReplaceAll(err.Error(), "\n", "; ")
+		causes = append(causes, fmt.Sprintf("%s%T: %s", strings.Repeat("  ", depth), err, message))
+
+		if wrapped, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, child := range wrapped.Unwrap() {
+				walk(child, depth+1)
+			}
+			return
+		}
+		walk(errors.Unwrap(err), depth+1)
+	}
+	walk(err, 0)
+	return causes
+}
+
+// withStack returns fields plus a "stack" field if stack traces are enabled
+// for level and fields has none yet. fields itself is never modified.
+func withStack(level LogLevel, fields []Field) []Field {
+	if level > ERROR || !stackTraces.Load() {
+		return fields
+	}
+	for _, field := range fields {
+		if field.Key == StackField {
+			return fields
+		}
+	}
+
+	stacked := make([]Field, len(fields), len(fields)+1)
+	copy(stacked, fields)
+	return append(stacked, Field{Key: StackField, Value: captureStack()})
+}
+
+// captureStack renders the calling goroutine's stack, starting at the first
+// frame outside this package and log/slog, in the format of debug.Stack.
+func captureStack() string {
+	const maxStackFrames = 64
+
+	pcs := make([]uintptr, maxStackFrames)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	var b strings.Builder
+	logging := true
+	for {
+		frame, more := frames.Next()
+		if logging && (strings.HasPrefix(frame.Function, loggerPackagePath+".") || strings.HasPrefix(frame.Function, "log/slog.")) {
+			if !more {
+				break
+			}
+			continue
+		}
+		logging = false
+
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		b.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
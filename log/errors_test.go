@@ -0,0 +1,135 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// loopError wraps itself, making an endless chain.
+type loopError struct{}
+
+func (e *loopError) Error() string { return "loop" }
+func (e *loopError) Unwrap() error { return e }
+
+func TestErrorCauses(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want Causes
+	}{
+		{"nil", nil, nil},
+		{"single", errA, Causes{"*errors.errorString: a failed"}},
+		{"chain", fmt.Errorf("load: %w", fmt.Errorf("open: %w", errA)), Causes{
+			"*fmt.wrapError: load: open: a failed",
+			"  *fmt.wrapError: open: a failed",
+			"    *errors.errorString: a failed",
+		}},
+		{"join", errors.Join(errA, fmt.Errorf("retry: %w", errB)), Causes{
+			"*errors.joinError: a failed; retry: b failed",
+			"  *errors.errorString: a failed",
+			"  *fmt.wrapError: retry: b failed",
+			"    *errors.errorString: b failed",
+		}},
+		{"several %w", fmt.Errorf("load config.yaml: %w, %w", errA, errB), Causes{
+			"*fmt.wrapErrors: load config.yaml: a failed, b failed",
+			"  *errors.errorString: a failed",
+			"  *errors.errorString: b failed",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCauses(tt.err); !slices.Equal(got, tt.want) {
+				t.Errorf("errorCauses() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCausesDepthCap(t *testing.T) {
+	causes := errorCauses(&loopError{})
+	if len(causes) != maxCauseDepth {
+		t.Fatalf("got %d causes, want %d", len(causes), maxCauseDepth)
+	}
+	if last := causes[len(causes)-1]; !strings.HasPrefix(last, strings.Repeat("  ", maxCauseDepth-1)+"*log.loopError") {
+		t.Errorf("deepest cause = %q", last)
+	}
+}
+
+func TestWithError(t *testing.T) {
+	logger := New("request_id", "r1")
+	if got := logger.WithError(nil); got != logger {
+		t.Error("WithError(nil) returned a new logger")
+	}
+
+	plain := logger.WithError(errors.New("boom"))
+	want := []Field{{Key: "request_id", Value: "r1"}, {Key: ErrorField, Value: "boom"}}
+	if got := plain.Fields(); !slices.Equal(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+
+	wrapped := logger.WithError(fmt.Errorf("save: %w", errors.New("disk full")))
+	fields := wrapped.Fields()
+	if len(fields) != 3 || fields[1].Value != "save: disk full" || fields[2].Key != CausesField {
+		t.Fatalf("fields = %v, want the error and its causes", fields)
+	}
+	causes, ok := fields[2].Value.(Causes)
+	if !ok || len(causes) != 2 {
+		t.Errorf("causes = %#v", fields[2].Value)
+	}
+	if got := causes.String(); got != "*fmt.wrapError: save: disk full\n  *errors.errorString: disk full" {
+		t.Errorf("causes print as %q", got)
+	}
+
+	if got := WithError(errors.New("boom")).Fields(); len(got) != 1 || got[0].Key != ErrorField {
+		t.Errorf("package WithError fields = %v", got)
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	sink := capture(t)
+	stack := func(message string) (string, bool) {
+		t.Helper()
+		for _, entry := range sink.all() {
+			if entry.Message == message {
+				value, ok := fieldValue(entry, StackField)
+				s, _ := value.(string)
+				return s, ok
+			}
+		}
+		t.Fatalf("%q was not logged", message)
+		return "", false
+	}
+
+	Error("stack disabled")
+	if _, ok := stack("stack disabled"); ok {
+		t.Error("stack added while stack traces were disabled")
+	}
+
+	SetStackTraces(true)
+	t.Cleanup(func() { SetStackTraces(false) })
+
+	Error("stack enabled")
+	trace, ok := stack("stack enabled")
+	if !ok {
+		t.Fatal("no stack on an ERROR entry")
+	}
+	// In-package frames are skipped, so the stack starts in the test runner.
+	if !strings.HasPrefix(trace, "testing.tRunner\n\t") || strings.Contains(trace, loggerPackagePath+".") {
+		t.Errorf("stack = %q, want it to start outside the logger", trace)
+	}
+
+	Warn("stack on warn")
+	if _, ok := stack("stack on warn"); ok {
+		t.Error("stack added to a WARN entry")
+	}
+
+	New(StackField, "own stack").Error("stack kept")
+	if trace, _ := stack("stack kept"); trace != "own stack" {
+		t.Errorf("stack = %q, want the entry's own", trace)
+	}
+}
@@ -1,81 +1,96 @@
 package log
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 )
 
+const fileSinkName = "file"
+
 var (
-	logFile     *os.File
 	logFileMu   sync.Mutex
 	logFilePath string = ""
 
-	currentFileLogs []string = []string{}
+	// fileLoggingStarted is set once the first log file has been opened, after
+	// which entries are no longer replayed from the recent-entry ring.
+	fileLoggingStarted bool
 )
 
-func logToFile(message string) {
-	currentFileLogs = append(currentFileLogs, message)
-	logFileMu.Lock()
-	defer logFileMu.Unlock()
+// FileOption configures the file destination opened by InitFileLogging.
+type FileOption func(*fileOptions)
 
-	if logFile == nil {
-		return
-	}
-	if _, err := fmt.Fprintf(logFile, "%s\n", message); err != nil {
-		fmt.Fprintf(os.Stderr, "%s ERROR: Failed to write internal message to log file %s: %v%s\n", colorRed, logFilePath, err, colorReset)
+type fileOptions struct {
+	encoder  Encoder
+	rotation Rotation
+}
+
+// WithFileEncoder selects how entries are written to the log file.
+// Defaults to TextEncoder.
+func WithFileEncoder(encoder Encoder) FileOption {
+	return func(o *fileOptions) {
+		o.encoder = encoder
 	}
 }
 
-func InitFileLogging(filePath string) error {
-	logFileMu.Lock()
-	defer logFileMu.Unlock()
+// InitFileLogging registers the "file" sink, which writes every entry
+// regardless of the global level. An empty path disables it. The first file
+// opened also receives the entries logged before it, as far as they are
+// still held by the recent-entry ring (see SetRecentSize).
+func InitFileLogging(filePath string, opts ...FileOption) error {
+	options := fileOptions{encoder: TextEncoder}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	if filePath == "" {
-		logInternal(INFO, nil, "File logging disabled (no path provided)")
+		logFileMu.Lock()
 		logFilePath = ""
-		if logFile != nil {
-			logFile.Close()
-			logFile = nil
-		}
-		return nil
-	}
+		logFileMu.Unlock()
+		RemoveSink(fileSinkName)
 
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0750); err != nil { // Changed permissions slightly
-		return fmt.Errorf("failed to create log directory '%s': %w", dir, err)
+		logInternal(INFO, nil, nil, "File logging disabled (no path provided)")
+		return nil
 	}
 
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // Changed permissions slightly
+	sink, err := NewFileSink(filePath, options.rotation)
 	if err != nil {
-		return fmt.Errorf("failed to open log file '%s': %w", filePath, err)
+		return err
 	}
 
-	if logFile != nil {
-		logFile.Close()
-	}
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
 
-	logFile = file
 	logFilePath = filePath
+	if fileLoggingStarted {
+		AddSink(fileSinkName, sink, WithLevel(DEBUG), WithEncoder(options.encoder))
+		return nil
+	}
 
-	if len(currentFileLogs) > 0 {
-		for _, logEntry := range currentFileLogs {
-			logToFile(logEntry)
-		}
+	// Replaying and registering under recentMu means every entry is either
+	// in the replay or written to the sink by deliverNow, never both.
+	recentMu.Lock()
+	for _, logEntry := range recentSnapshot() {
+		sink.Write(logEntry, options.encoder.Encode(logEntry))
 	}
+	AddSink(fileSinkName, sink, WithLevel(DEBUG), WithEncoder(options.encoder))
+	recentMu.Unlock()
+	fileLoggingStarted = true
 
 	return nil
 }
 
 func CloseFile() {
 	logFileMu.Lock()
-	defer logFileMu.Unlock()
+	path := logFilePath
+	logFileMu.Unlock()
 
-	if logFile != nil {
-		logInternal(INFO, nil, "Closing log file: %s", logFilePath)
-		logFile.Close()
-		logFile = nil
-		logFilePath = ""
+	if path == "" {
+		return
 	}
+	logInternal(INFO, nil, nil, "Closing log file: %s", path)
+
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+
+	RemoveSink(fileSinkName)
+	logFilePath = ""
 }
@@ -0,0 +1,104 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// resetFileLogging clears the recent-entry ring and lets the next
+// InitFileLogging replay it again, closing the log file when the test ends.
+func resetFileLogging(t *testing.T) {
+	t.Helper()
+	SetRecentSize(0)
+	SetRecentSize(DefaultRecentSize)
+
+	logFileMu.Lock()
+	fileLoggingStarted = false
+	logFileMu.Unlock()
+	t.Cleanup(CloseFile)
+}
+
+// countLines returns how many lines of the file end with message.
+func countLines(t *testing.T, name, message string) int {
+	t.Helper()
+	n := 0
+	for _, line := range strings.Split(readFile(t, name), "\n") {
+		if strings.HasSuffix(line, message) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestInitFileLoggingReplaysOnce(t *testing.T) {
+	resetFileLogging(t)
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+
+	Info("before the first file")
+	if err := InitFileLogging(first); err != nil {
+		t.Fatal(err)
+	}
+	Info("into the first file")
+	if err := InitFileLogging(second); err != nil {
+		t.Fatal(err)
+	}
+	Info("into the second file")
+	CloseFile()
+
+	for _, tt := range []struct {
+		file    string
+		message string
+		want    int
+	}{
+		{first, "before the first file", 1},
+		{first, "into the first file", 1},
+		{first, "into the second file", 0},
+		{second, "before the first file", 0},
+		{second, "into the first file", 0},
+		{second, "into the second file", 1},
+	} {
+		if got := countLines(t, tt.file, tt.message); got != tt.want {
+			t.Errorf("%s holds %q %d times, want %d", filepath.Base(tt.file), tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestInitFileLoggingWhileLogging(t *testing.T) {
+	resetFileLogging(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	const goroutines, perGoroutine = 4, 100
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for i := 0; i < perGoroutine; i++ {
+				Infof("concurrent %d-%d", g, i)
+			}
+		}()
+	}
+
+	close(start)
+	if err := InitFileLogging(path); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	CloseFile()
+
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < perGoroutine; i++ {
+			message := fmt.Sprintf("concurrent %d-%d", g, i)
+			if got := countLines(t, path, message); got != 1 {
+				t.Fatalf("file holds %q %d times, want 1", message, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package log
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FluentMode selects how a FluentSink frames its batches.
+type FluentMode int
+
+const (
+	// FluentForward sends each batch as an array of [time, record] entries.
+	FluentForward FluentMode = iota
+	// FluentPackedForward sends each batch as one binary holding the
+	// concatenated [time, record] entries, which is cheaper to decode.
+	FluentPackedForward
+)
+
+// FluentConfig describes where and how a FluentSink sends entries.
+type FluentConfig struct {
+	// Network is "tcp" or "unix". Defaults to "tcp".
+	Network string
+	// Address defaults to "127.0.0.1:24224" for "tcp".
+	Address string
+	// Tag is the Fluent tag of every entry. Defaults to the executable name.
+	Tag  string
+	Mode FluentMode
+	// RequireAck asks the agent to acknowledge every batch. Batches that are
+	// not acknowledged within Timeout are sent again, so entries may arrive
+	// twice but are not lost to a dropped connection.
+	RequireAck bool
+
+	// BatchSize is the number of entries that triggers a send. Defaults to 100.
+	BatchSize int
+	// FlushInterval is the longest an entry waits for its batch to fill.
+	// Defaults to 1s.
+	FlushInterval time.Duration
+	// BufferSize is the number of entries held while disconnected; the
+	// oldest are dropped once it is full. Defaults to 8192.
+	BufferSize int
+	// ReconnectDelay is the minimum time between connection attempts. Defaults to 1s.
+	ReconnectDelay time.Duration
+	// Timeout bounds dialing, each write and each ack. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// FluentSink sends entries to a Fluentd or Fluent Bit forward input. Each
+// entry becomes a record with "level", "caller", "msg" and the entry's
+// fields, timestamped with nanosecond precision. It encodes entries itself,
+// so the encoder it is registered with is not used.
+type FluentSink struct {
+	config FluentConfig
+	writer *reconnectingWriter
+}
+
+// fluentConn is a connection to the forward input with the reader acks are
+// read through.
+type fluentConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// NewFluentSink connects to the configured forward input. If only the
+// connection fails, the sink is returned along with the error: it can still
+// be registered, buffering entries and reconnecting later.
+func NewFluentSink(config FluentConfig) (*FluentSink, error) {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6":
+		if config.Address == "" {
+			config.Address = "127.0.0.1:24224"
+		}
+	case "unix":
+		if config.Address == "" {
+			return nil, fmt.Errorf("fluent unix socket path is required")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported fluent network: %s", config.Network)
+	}
+	if config.Tag == "" {
+		config.Tag = filepath.Base(os.Args[0])
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.BufferSize < config.BatchSize {
+		config.BufferSize = max(8192, config.BatchSize)
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	s := &FluentSink{config: config}
+	s.writer = &reconnectingWriter{
+		name:           "fluent",
+		unit:           "entries",
+		bufferSize:     config.BufferSize,
+		batchSize:      config.BatchSize,
+		interval:       config.FlushInterval,
+		reconnectDelay: config.ReconnectDelay,
+		dial:           s.dial,
+		send:           s.send,
+	}
+	if err := s.writer.start(); err != nil {
+		return s, fmt.Errorf("failed to connect to fluent: %w", err)
+	}
+	return s, nil
+}
+
+func (s *FluentSink) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(s.config.Network, s.config.Address, s.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &fluentConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (s *FluentSink) Write(entry *Entry, _ string) error {
+	s.writer.write(s.encodeEntry(entry))
+	return nil
+}
+
+// encodeEntry encodes an entry as a [time, record] pair.
+func (s *FluentSink) encodeEntry(entry *Entry) []byte {
+	var e msgpackEncoder
+	e.writeArrayHeader(2)
+	e.writeEventTime(entry.Time)
+	e.writeMapHeader(len(entry.Fields) + 3)
+	e.writeString(levelKey)
+	e.writeString(entry.Level.String())
+	e.writeString(callerKey)
+	e.writeString(formatCaller(entry))
+	e.writeString(msgKey)
+	e.writeString(entry.Message)
+	for _, field := range entry.Fields {
+		e.writeString(fieldKey(field.Key))
+		e.writeValue(field.Value)
+	}
+	return e.buf
+}
+
+// encodeBatch encodes a Forward or PackedForward message carrying events.
+func (s *FluentSink) encodeBatch(events [][]byte, chunk string) []byte {
+	var e msgpackEncoder
+	e.writeArrayHeader(3)
+	e.writeString(s.config.Tag)
+
+	if s.config.Mode == FluentPackedForward {
+		size := 0
+		for _, event := range events {
+			size += len(event)
+		}
+		packed := make([]byte, 0, size)
+		for _, event := range events {
+			packed = append(packed, event...)
+		}
+		e.writeBinary(packed)
+	} else {
+		e.writeArrayHeader(len(events))
+		for _, event := range events {
+			e.buf = append(e.buf, event...)
+		}
+	}
+
+	if chunk != "" {
+		e.writeMapHeader(2)
+		e.writeString("chunk")
+		e.writeString(chunk)
+	} else {
+		e.writeMapHeader(1)
+	}
+	e.writeString("size")
+	e.writeInt(int64(len(events)))
+	return e.buf
+}
+
+func (s *FluentSink) send(conn net.Conn, batch [][]byte) error {
+	var chunk string
+	if s.config.RequireAck {
+		id := make([]byte, 16)
+		rand.Read(id)
+		chunk = base64.StdEncoding.EncodeToString(id)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	if _, err := conn.Write(s.encodeBatch(batch, chunk)); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	conn.SetReadDeadline(time.Now().Add(s.config.Timeout))
+	response, err := readMsgpackStringMap(conn.(*fluentConn).reader)
+	if err != nil {
+		return fmt.Errorf("failed to read fluent ack: %w", err)
+	}
+	if response["ack"] != chunk {
+		return fmt.Errorf("fluent ack %q does not match chunk %q", response["ack"], chunk)
+	}
+	return nil
+}
+
+// Flush sends any buffered entries, reconnecting immediately if needed.
+func (s *FluentSink) Flush() error {
+	return s.writer.flush()
+}
+
+// Close sends any buffered entries and closes the connection.
+func (s *FluentSink) Close() error {
+	return s.writer.close()
+}
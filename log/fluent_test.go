@@ -0,0 +1,235 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fluentServer listens on a unix socket and hands over each connection the
+// sink opens.
+func fluentServer(t *testing.T) (string, <-chan net.Conn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fluent.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			conns <- conn
+		}
+	}()
+	return path, conns
+}
+
+func nextFluentConn(t *testing.T, conns <-chan net.Conn) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	select {
+	case conn := <-conns:
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sink did not connect")
+		return nil, nil
+	}
+}
+
+// fluentMessage is a decoded Forward or PackedForward message.
+type fluentMessage struct {
+	tag     string
+	events  []any
+	options map[string]any
+}
+
+// readFluentMessage reads one message, unpacking the events of a
+// PackedForward message.
+func readFluentMessage(t *testing.T, r *bufio.Reader) fluentMessage {
+	t.Helper()
+	value, err := decodeMsgpack(r)
+	if err != nil {
+		t.Fatalf("reading fluent message: %v", err)
+	}
+	parts, ok := value.([]any)
+	if !ok || len(parts) != 3 {
+		t.Fatalf("message = %#v, want [tag, entries, options]", value)
+	}
+
+	var m fluentMessage
+	m.tag, _ = parts[0].(string)
+	m.options, _ = parts[2].(map[string]any)
+	switch entries := parts[1].(type) {
+	case []any:
+		m.events = entries
+	case []byte:
+		packed := bufio.NewReader(bytes.NewReader(entries))
+		for {
+			if _, err := packed.Peek(1); err != nil {
+				break
+			}
+			event, err := decodeMsgpack(packed)
+			if err != nil {
+				t.Fatalf("unpacking entries: %v", err)
+			}
+			m.events = append(m.events, event)
+		}
+	default:
+		t.Fatalf("entries = %#v", parts[1])
+	}
+	return m
+}
+
+// messages returns the "msg" of each event, checking each is a
+// [EventTime, record] pair.
+func (m fluentMessage) messages(t *testing.T) []string {
+	t.Helper()
+	var messages []string
+	for _, event := range m.events {
+		pair, ok := event.([]any)
+		if !ok || len(pair) != 2 {
+			t.Fatalf("event = %#v, want [time, record]", event)
+		}
+		if _, ok := pair[0].(time.Time); !ok {
+			t.Errorf("event time = %#v, want an EventTime", pair[0])
+		}
+		record, _ := pair[1].(map[string]any)
+		message, _ := record[msgKey].(string)
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func newTestFluentSink(t *testing.T, config FluentConfig) *FluentSink {
+	t.Helper()
+	config.Network = "unix"
+	config.Tag = "app"
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Hour
+	}
+	s, err := NewFluentSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func writeFluent(s *FluentSink, messages ...string) {
+	for _, message := range messages {
+		s.Write(&Entry{Time: time.Now(), Level: WARN, Message: message}, "")
+	}
+}
+
+func TestFluentFraming(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		mode FluentMode
+	}{
+		{"forward", FluentForward},
+		{"packed forward", FluentPackedForward},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path, conns := fluentServer(t)
+			s := newTestFluentSink(t, FluentConfig{Address: path, Mode: tt.mode, BatchSize: 2})
+			_, r := nextFluentConn(t, conns)
+
+			when := time.Unix(1700000000, 123456789)
+			s.Write(&Entry{Time: when, Level: WARN, Message: "first", Fields: []Field{{Key: "user", Value: "ada"}}}, "")
+			writeFluent(s, "second")
+
+			m := readFluentMessage(t, r)
+			if m.tag != "app" {
+				t.Errorf("tag = %q, want %q", m.tag, "app")
+			}
+			if got := m.messages(t); !slices.Equal(got, []string{"first", "second"}) {
+				t.Errorf("messages = %q", got)
+			}
+			if size, _ := m.options["size"].(uint64); size != 2 {
+				t.Errorf("options = %v, want size 2", m.options)
+			}
+			if _, ok := m.options["chunk"]; ok {
+				t.Errorf("options = %v, want no chunk without RequireAck", m.options)
+			}
+
+			first := m.events[0].([]any)
+			if got := first[0].(time.Time); !got.Equal(when) {
+				t.Errorf("event time = %v, want %v", got, when)
+			}
+			record := first[1].(map[string]any)
+			if record[levelKey] != "WARN" || record["user"] != "ada" {
+				t.Errorf("record = %v", record)
+			}
+		})
+	}
+}
+
+func TestFluentResendsUnacknowledgedBatch(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ack  func(chunk string) []byte
+	}{
+		{"missing ack", func(string) []byte { return nil }},
+		{"wrong ack", func(string) []byte {
+			var e msgpackEncoder
+			e.writeMapHeader(1)
+			e.writeString("ack")
+			e.writeString("not the chunk")
+			return e.buf
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path, conns := fluentServer(t)
+			s := newTestFluentSink(t, FluentConfig{
+				Address:    path,
+				RequireAck: true,
+				BatchSize:  10,
+				Timeout:    100 * time.Millisecond,
+			})
+			conn, r := nextFluentConn(t, conns)
+
+			writeFluent(s, "a", "b")
+			flushed := make(chan error, 1)
+			go func() { flushed <- s.Flush() }()
+
+			m := readFluentMessage(t, r)
+			chunk, _ := m.options["chunk"].(string)
+			if chunk == "" {
+				t.Fatalf("options = %v, want a chunk", m.options)
+			}
+			if response := tt.ack(chunk); response != nil {
+				conn.Write(response)
+			}
+			if err := <-flushed; err == nil {
+				t.Fatal("Flush succeeded without an ack")
+			}
+
+			go func() { flushed <- s.Flush() }()
+			conn, r = nextFluentConn(t, conns)
+			m = readFluentMessage(t, r)
+			if got := m.messages(t); !slices.Equal(got, []string{"a", "b"}) {
+				t.Errorf("resent %q, want the unacknowledged batch", got)
+			}
+			resent, _ := m.options["chunk"].(string)
+			var e msgpackEncoder
+			e.writeMapHeader(1)
+			e.writeString("ack")
+			e.writeString(resent)
+			conn.Write(e.buf)
+			if err := <-flushed; err != nil {
+				t.Fatalf("Flush() after the ack = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Hook is called with entries as they are logged, before they reach the
+// sinks, for example to alert or count errors. A hook must not log through
+// this package at a level it fires for, or it calls itself.
+type Hook interface {
+	Fire(entry *Entry) error
+}
+
+// HookFunc adapts a function to the Hook interface.
+type HookFunc func(entry *Entry) error
+
+func (f HookFunc) Fire(entry *Entry) error {
+	return f(entry)
+}
+
+// HookOption configures how entries are passed to a hook registered with AddHook.
+type HookOption func(*registeredHook)
+
+// WithHookLevel sets the most verbose level the hook fires for. Without it
+// the hook follows the global level, like a sink registered without WithLevel.
+func WithHookLevel(level LogLevel) HookOption {
+	return func(h *registeredHook) {
+		h.level = level
+		h.followGlobal = false
+	}
+}
+
+// WithAsyncHook runs the hook on its own goroutine, fed by a queue of
+// queueSize entries (1024 if queueSize <= 0). Entries arriving while the
+// queue is full are dropped and counted in a message on stderr.
+func WithAsyncHook(queueSize int) HookOption {
+	return func(h *registeredHook) {
+		if queueSize <= 0 {
+			queueSize = 1024
+		}
+		h.queue = make(chan *Entry, queueSize)
+	}
+}
+
+type registeredHook struct {
+	name         string
+	hook         Hook
+	level        LogLevel
+	followGlobal bool
+
+	// Only set for asynchronous hooks.
+	queue   chan *Entry
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending int
+	dropped int
+	closed  bool
+	done    chan struct{}
+}
+
+var (
+	hooks   []*registeredHook
+	hooksMu sync.RWMutex
+)
+
+// AddHook registers a hook under name. A hook already registered under the
+// same name is replaced and closed as by RemoveHook.
+//
+//	log.AddHook("alerts", log.HookFunc(notify), log.WithHookLevel(log.ERROR), log.WithAsyncHook(0))
+func AddHook(name string, hook Hook, opts ...HookOption) {
+	h := &registeredHook{
+		name:         name,
+		hook:         hook,
+		followGlobal: true,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.queue != nil {
+		h.idle = sync.NewCond(&h.mu)
+		h.done = make(chan struct{})
+		go h.run()
+	}
+
+	hooksMu.Lock()
+	var old *registeredHook
+	updated := make([]*registeredHook, 0, len(hooks)+1)
+	for _, existing := range hooks {
+		if existing.name == name {
+			old = existing
+			updated = append(updated, h)
+			continue
+		}
+		updated = append(updated, existing)
+	}
+	if old == nil {
+		updated = append(updated, h)
+	}
+	hooks = updated
+	hooksMu.Unlock()
+
+	if old != nil {
+		closeHook(old)
+	}
+}
+
+// RemoveHook unregisters the hook registered under name. Entries queued for
+// an asynchronous hook are passed to it first, then the hook is flushed and
+// closed if it has Flush or Close methods.
+func RemoveHook(name string) error {
+	hooksMu.Lock()
+	var removed *registeredHook
+	updated := make([]*registeredHook, 0, len(hooks))
+	for _, existing := range hooks {
+		if existing.name == name {
+			removed = existing
+			continue
+		}
+		updated = append(updated, existing)
+	}
+	hooks = updated
+	hooksMu.Unlock()
+
+	if removed == nil {
+		return fmt.Errorf("no hook registered as '%s'", name)
+	}
+	return closeHook(removed)
+}
+
+func closeHook(h *registeredHook) error {
+	if h.queue != nil {
+		h.mu.Lock()
+		h.closed = true
+		close(h.queue)
+		h.mu.Unlock()
+		<-h.done
+	}
+
+	if flusher, ok := h.hook.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return fmt.Errorf("failed to flush hook '%s': %w", h.name, err)
+		}
+	}
+	if closer, ok := h.hook.(interface{ Close() error }); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("failed to close hook '%s': %w", h.name, err)
+		}
+	}
+	return nil
+}
+
+// fireHooks passes an entry to every hook whose level allows it.
+func fireHooks(entry *Entry) {
+	hooksMu.RLock()
+	current := hooks
+	hooksMu.RUnlock()
+
+	for _, h := range current {
+		if !levelEnabled(entry, h.level, h.followGlobal) {
+			continue
+		}
+		if h.queue == nil {
+			h.fire(entry)
+			continue
+		}
+
+		h.mu.Lock()
+		if !h.closed {
+			select {
+			case h.queue <- entry:
+				h.pending++
+			default:
+				h.dropped++
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// fire calls the hook, reporting errors and panics on stderr so that a
+// failing hook cannot break logging.
+func (h *registeredHook) fire(entry *Entry) {
+	defer func() {
+		if value := recover(); value != nil {
+			reportError("Log hook %s panicked: %v", h.name, value)
+		}
+	}()
+
+	if err := h.hook.Fire(entry); err != nil {
+		reportError("Log hook %s failed: %v", h.name, err)
+	}
+}
+
+func (h *registeredHook) run() {
+	defer close(h.done)
+
+	for entry := range h.queue {
+		h.fire(entry)
+
+		h.mu.Lock()
+		h.pending--
+		dropped := 0
+		if h.pending == 0 {
+			dropped, h.dropped = h.dropped, 0
+			h.idle.Broadcast()
+		}
+		h.mu.Unlock()
+
+		if dropped > 0 {
+			reportError("Dropped %d entries for log hook %s because its queue was full", dropped, h.name)
+		}
+	}
+}
+
+// wait blocks until the hook's queue is empty or ctx is done.
+func (h *registeredHook) wait(ctx context.Context) error {
+	idle := make(chan struct{})
+	go func() {
+		h.mu.Lock()
+		for h.pending > 0 && ctx.Err() == nil {
+			h.idle.Wait()
+		}
+		h.mu.Unlock()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		h.mu.Lock()
+		h.idle.Broadcast()
+		h.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// flushHooks waits for asynchronous hooks to drain, then flushes the hooks
+// that have a Flush method.
+func flushHooks(ctx context.Context) []error {
+	hooksMu.RLock()
+	current := hooks
+	hooksMu.RUnlock()
+
+	var errs []error
+	for _, h := range current {
+		if h.queue != nil {
+			if err := h.wait(ctx); err != nil {
+				return append(errs, err)
+			}
+		}
+		if flusher, ok := h.hook.(interface{ Flush() error }); ok {
+			if err := flusher.Flush(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to flush hook '%s': %w", h.name, err))
+			}
+		}
+	}
+	return errs
+}
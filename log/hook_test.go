@@ -0,0 +1,151 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedHook records the messages it is fired with, blocking until it is
+// opened. Tests defer open so RemoveHook can drain its queue.
+type gatedHook struct {
+	started  chan struct{}
+	gate     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+	messages []string
+}
+
+func newGatedHook() *gatedHook {
+	return &gatedHook{started: make(chan struct{}, 1), gate: make(chan struct{})}
+}
+
+func (h *gatedHook) Fire(entry *Entry) error {
+	select {
+	case h.started <- struct{}{}:
+	default:
+	}
+	<-h.gate
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, entry.Message)
+	return nil
+}
+
+func (h *gatedHook) open() { h.once.Do(func() { close(h.gate) }) }
+
+func (h *gatedHook) fired() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.messages...)
+}
+
+// addHook registers hook under name for the rest of the test.
+func addHook(t *testing.T, name string, hook Hook, opts ...HookOption) *registeredHook {
+	t.Helper()
+	AddHook(name, hook, opts...)
+	t.Cleanup(func() { RemoveHook(name) })
+
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, h := range hooks {
+		if h.name == name {
+			return h
+		}
+	}
+	t.Fatal("hook not registered")
+	return nil
+}
+
+func TestHookPanicIsIsolated(t *testing.T) {
+	sink := capture(t)
+	addHook(t, "panicking", HookFunc(func(entry *Entry) error { panic("hook exploded") }))
+	addHook(t, "failing", HookFunc(func(entry *Entry) error { return errors.New("hook failed") }))
+	stderr := captureStderr(t)
+
+	Info("survives a panicking hook")
+
+	if got := sink.messages(); len(got) != 1 || got[0] != "survives a panicking hook" {
+		t.Errorf("sink received %q", got)
+	}
+	output := stderr()
+	if !strings.Contains(output, "Log hook panicking panicked: hook exploded") {
+		t.Errorf("stderr = %q, want the panic reported", output)
+	}
+	if !strings.Contains(output, "Log hook failing failed: hook failed") {
+		t.Errorf("stderr = %q, want the error reported", output)
+	}
+}
+
+func TestAsyncHookCountsDrops(t *testing.T) {
+	hook := newGatedHook()
+	defer hook.open()
+	h := addHook(t, "async", hook, WithAsyncHook(1))
+
+	Info("async hook 0")
+	select {
+	case <-hook.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hook goroutine never fired")
+	}
+	Info("async hook 1") // fills the queue
+	Info("async hook 2")
+	Info("async hook 3")
+
+	h.mu.Lock()
+	dropped := h.dropped
+	h.mu.Unlock()
+	if dropped != 2 {
+		t.Errorf("dropped = %d, want 2", dropped)
+	}
+
+	hook.open()
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"async hook 0", "async hook 1"}
+	if got := hook.fired(); !slices.Equal(got, want) {
+		t.Errorf("hook fired with %q, want %q", got, want)
+	}
+}
+
+func TestFlushWaitsForAsyncHooks(t *testing.T) {
+	hook := newGatedHook()
+	defer hook.open()
+	addHook(t, "async", hook, WithAsyncHook(16))
+
+	Info("flushed hook 0")
+	Info("flushed hook 1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Flush() with a blocked hook = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- Flush(context.Background()) }()
+	select {
+	case err := <-flushed:
+		t.Fatalf("Flush returned %v while the hook was blocked", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	hook.open()
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush did not return once the hook drained")
+	}
+	if got := hook.fired(); len(got) != 2 {
+		t.Errorf("hook fired %d times by the time Flush returned, want 2", len(got))
+	}
+}
@@ -26,13 +26,13 @@ var levelNames = map[LogLevel]string{
 	REQUEST: "REQ",
 }
 
-var levelColors = map[LogLevel]string{
-	PANIC:   colorRed,
-	ERROR:   colorRed,
-	WARN:    colorYellow,
-	INFO:    colorBlue,
-	DEBUG:   colorYellow, // Keep debug yellow? Or choose another?
-	REQUEST: colorDarkGrey,
+// String returns the name the level is logged under, e.g. "WARN", or "REQ"
+// for REQUEST.
+func (l LogLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
 }
 
 var levelMutex sync.RWMutex
@@ -40,41 +40,49 @@ var levelMutex sync.RWMutex
 // SetLevelFromString sets the minimum log level based on a string identifier.
 // Valid levels: "debug", "info", "warn", "error", "panic". Case-insensitive.
 func SetLevelFromString(levelStr string) error {
-	levelStr = strings.ToLower(levelStr)
-	var level LogLevel
-	switch levelStr {
+	level, err := ParseLevel(levelStr)
+	if err != nil {
+		return err
+	}
+	SetLevel(level)
+	return nil
+}
+
+// ParseLevel returns the level named by levelStr, using the names accepted
+// by SetLevelFromString.
+func ParseLevel(levelStr string) (LogLevel, error) {
+	switch strings.ToLower(levelStr) {
 	case "debug":
-		level = DEBUG
+		return DEBUG, nil
 	case "info":
-		level = INFO
+		return INFO, nil
 	case "warn":
-		level = WARN
+		return WARN, nil
 	case "error":
-		level = ERROR
+		return ERROR, nil
 	case "panic":
-		level = PANIC
+		return PANIC, nil
 	default:
-		return fmt.Errorf("invalid log level: %s", levelStr)
+		return 0, fmt.Errorf("invalid log level: %s", levelStr)
 	}
-	SetLevel(level)
-	return nil
 }
 
 func SetLevel(level LogLevel) {
+	levelMutex.Lock()
 	if level == currentLevel {
+		levelMutex.Unlock()
 		return
 	}
-
-	levelMutex.Lock()
 	currentLevel = level
-	logInternal(INFO, nil, "Log level set to %s", levelNames[level])
 	levelMutex.Unlock()
+
 	updateLogFunctions()
+	logInternal(INFO, nil, nil, "Log level set to %s", level)
 }
 
 // GetLevel returns the current minimum log level.
 func GetLevel() LogLevel {
-	// levelMutex.RLock()
-	// defer levelMutex.RUnlock()
+	levelMutex.RLock()
+	defer levelMutex.RUnlock()
 	return currentLevel
 }
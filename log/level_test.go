@@ -0,0 +1,28 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLogLevelString(t *testing.T) {
+	for _, tt := range []struct {
+		level LogLevel
+		want  string
+	}{
+		{PANIC, "PANIC"},
+		{ERROR, "ERROR"},
+		{WARN, "WARN"},
+		{INFO, "INFO"},
+		{DEBUG, "DEBUG"},
+		{REQUEST, "REQ"},
+		{LogLevel(42), "LogLevel(42)"},
+	} {
+		if got := tt.level.String(); got != tt.want {
+			t.Errorf("%d.String() = %q, want %q", int(tt.level), got, tt.want)
+		}
+		if got := fmt.Sprint(tt.level); got != tt.want {
+			t.Errorf("fmt.Sprint(%d) = %q, want %q", int(tt.level), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LevelHandlerOption configures LevelHandler.
+type LevelHandlerOption func(*levelHandlerOptions)
+
+type levelHandlerOptions struct {
+	revertAfter time.Duration
+}
+
+// WithRevertAfter sets the TTL applied to changes that do not specify one,
+// after which the previous level and spec are restored. Defaults to 0, which
+// keeps changes until the next one.
+func WithRevertAfter(ttl time.Duration) LevelHandlerOption {
+	return func(o *levelHandlerOptions) {
+		o.revertAfter = ttl
+	}
+}
+
+// levelState is the response of LevelHandler.
+type levelState struct {
+	Level    string     `json:"level"`
+	Spec     string     `json:"spec"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// levelChange is the request body accepted by LevelHandler. Omitted fields
+// are left unchanged.
+type levelChange struct {
+	Level *string `json:"level"`
+	Spec  *string `json:"spec"`
+	TTL   *string `json:"ttl"`
+}
+
+type levelHandler struct {
+	options levelHandlerOptions
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	revertAt time.Time
+	changes  uint64 // identifies the change timer reverts
+
+	// The level and spec restored when timer fires.
+	savedLevel LogLevel
+	savedSpec  string
+}
+
+// LevelHandler returns a handler for inspecting and changing the log level
+// of a running process. GET returns the global level, the SetLevelSpec spec
+// and the pending revert time, if any, as JSON. PUT accepts the same fields
+// plus a ttl, all optional:
+//
+//	curl -X PUT -d '{"level":"debug","spec":"db=debug","ttl":"10m"}' localhost:8080/debug/level
+//
+// When the ttl is positive, the level and spec in effect before the first
+// change are restored once it expires. A change with a ttl of "0" keeps
+// the new state and cancels a pending revert; negative ttls are rejected.
+func LevelHandler(opts ...LevelHandlerOption) http.Handler {
+	h := &levelHandler{}
+	for _, opt := range opts {
+		opt(&h.options)
+	}
+	return h
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if err := h.change(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.state())
+}
+
+func (h *levelHandler) state() levelState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := levelState{Level: GetLevel().String(), Spec: LevelSpec()}
+	if h.timer != nil {
+		revertAt := h.revertAt
+		state.RevertAt = &revertAt
+	}
+	return state
+}
+
+func (h *levelHandler) change(w http.ResponseWriter, r *http.Request) error {
+	var change levelChange
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&change); err != nil {
+		return fmt.Errorf("failed to decode level change: %w", err)
+	}
+
+	// Validate everything before changing anything.
+	level := GetLevel()
+	if change.Level != nil {
+		parsed, err := ParseLevel(*change.Level)
+		if err != nil {
+			return err
+		}
+		level = parsed
+	}
+	if change.Spec != nil {
+		if _, err := parseLevelSpec(*change.Spec); err != nil {
+			return err
+		}
+	}
+	ttl := h.options.revertAfter
+	if change.TTL != nil {
+		parsed, err := time.ParseDuration(*change.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid ttl '%s': %w", *change.TTL, err)
+		}
+		if parsed < 0 {
+			return fmt.Errorf("invalid ttl '%s': must not be negative", *change.TTL)
+		}
+		ttl = parsed
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.timer == nil {
+		h.savedLevel, h.savedSpec = GetLevel(), LevelSpec()
+	} else {
+		h.timer.Stop()
+		h.timer = nil
+	}
+
+	SetLevel(level)
+	if change.Spec != nil {
+		SetLevelSpec(*change.Spec)
+	}
+
+	if ttl > 0 {
+		h.changes++
+		id := h.changes
+		h.revertAt = time.Now().Add(ttl)
+		h.timer = time.AfterFunc(ttl, func() { h.revert(id) })
+		logInternal(INFO, nil, nil, "Log level change reverts at %s", h.revertAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// revert restores the state saved before the first change with a TTL,
+// unless a later change replaced or cancelled the revert.
+func (h *levelHandler) revert(id uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.timer == nil || h.changes != id {
+		return
+	}
+	h.timer = nil
+
+	SetLevel(h.savedLevel)
+	if h.savedSpec != LevelSpec() {
+		SetLevelSpec(h.savedSpec)
+	}
+	logInternal(INFO, nil, nil, "Reverted log level to %s", h.savedLevel)
+}
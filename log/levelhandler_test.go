@@ -0,0 +1,183 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keepLevels restores the global level and level spec after the test.
+func keepLevels(t *testing.T) {
+	t.Helper()
+	level, spec := GetLevel(), LevelSpec()
+	t.Cleanup(func() {
+		SetLevel(level)
+		SetLevelSpec(spec)
+	})
+}
+
+// serveLevel sends a request to h and decodes the state it returns.
+func serveLevel(t *testing.T, h http.Handler, method, body string) (*httptest.ResponseRecorder, levelState) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/debug/level", strings.NewReader(body)))
+
+	var state levelState
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatalf("decoding state: %v", err)
+		}
+	}
+	return rec, state
+}
+
+// waitForLevel waits for the global level to become level.
+func waitForLevel(t *testing.T, level LogLevel) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for GetLevel() != level {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %v, want %v", GetLevel(), level)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLevelHandlerGet(t *testing.T) {
+	keepLevels(t)
+	SetLevel(WARN)
+	setLevelSpec(t, "db=debug")
+
+	rec, state := serveLevel(t, LevelHandler(), http.MethodGet, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if state.Level != "WARN" || state.Spec != "db=debug" || state.RevertAt != nil {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestLevelHandlerPut(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	h := LevelHandler()
+
+	rec, state := serveLevel(t, h, http.MethodPut, `{"level":"error","spec":"db=debug"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if GetLevel() != ERROR || LevelSpec() != "db=debug" {
+		t.Errorf("level = %v, spec = %q after PUT", GetLevel(), LevelSpec())
+	}
+	if state.Level != "ERROR" || state.Spec != "db=debug" || state.RevertAt != nil {
+		t.Errorf("state = %+v", state)
+	}
+
+	// Omitted fields are left alone.
+	serveLevel(t, h, http.MethodPut, `{"level":"warn"}`)
+	if GetLevel() != WARN || LevelSpec() != "db=debug" {
+		t.Errorf("level = %v, spec = %q after changing only the level", GetLevel(), LevelSpec())
+	}
+}
+
+func TestLevelHandlerRejectsInvalidChanges(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	setLevelSpec(t, "db=warn")
+	h := LevelHandler()
+
+	for _, tt := range []struct {
+		name string
+		body string
+		want string
+	}{
+		{"malformed body", `{"level":`, "failed to decode level change"},
+		{"unknown level", `{"level":"loud"}`, "invalid log level: loud"},
+		// The valid level must not be applied when the spec is invalid.
+		{"invalid spec", `{"level":"debug","spec":"db"}`, "invalid level spec rule 'db'"},
+		{"invalid ttl", `{"level":"debug","ttl":"soon"}`, "invalid ttl 'soon'"},
+		{"negative ttl", `{"level":"debug","ttl":"-5m"}`, "invalid ttl '-5m': must not be negative"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := serveLevel(t, h, http.MethodPut, tt.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.want)
+			}
+			if GetLevel() != INFO || LevelSpec() != "db=warn" {
+				t.Errorf("level = %v, spec = %q after a rejected change", GetLevel(), LevelSpec())
+			}
+		})
+	}
+}
+
+func TestLevelHandlerMethodNotAllowed(t *testing.T) {
+	rec, _ := serveLevel(t, LevelHandler(), http.MethodPost, `{"level":"debug"}`)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET, HEAD, PUT" {
+		t.Errorf("Allow = %q", allow)
+	}
+}
+
+func TestLevelHandlerRevertsAfterTTL(t *testing.T) {
+	keepLevels(t)
+	SetLevel(WARN)
+	setLevelSpec(t, "db=warn")
+	h := LevelHandler()
+
+	// A second change before the revert keeps the state from before the first.
+	serveLevel(t, h, http.MethodPut, `{"level":"info","ttl":"1h"}`)
+	_, state := serveLevel(t, h, http.MethodPut, `{"level":"debug","spec":"db=debug","ttl":"50ms"}`)
+	if state.RevertAt == nil || time.Until(*state.RevertAt) > time.Second {
+		t.Fatalf("revert_at = %v, want about 50ms from now", state.RevertAt)
+	}
+	if GetLevel() != DEBUG || LevelSpec() != "db=debug" {
+		t.Fatalf("level = %v, spec = %q before the revert", GetLevel(), LevelSpec())
+	}
+
+	waitForLevel(t, WARN)
+	if spec := LevelSpec(); spec != "db=warn" {
+		t.Errorf("spec = %q after the revert, want %q", spec, "db=warn")
+	}
+	if _, state := serveLevel(t, h, http.MethodGet, ""); state.RevertAt != nil {
+		t.Errorf("revert_at = %v after the revert", state.RevertAt)
+	}
+}
+
+func TestLevelHandlerDefaultTTL(t *testing.T) {
+	keepLevels(t)
+	SetLevel(WARN)
+	h := LevelHandler(WithRevertAfter(50 * time.Millisecond))
+
+	if _, state := serveLevel(t, h, http.MethodPut, `{"level":"debug"}`); state.RevertAt == nil {
+		t.Fatal("no revert scheduled with WithRevertAfter")
+	}
+	waitForLevel(t, WARN)
+}
+
+func TestLevelHandlerZeroTTLCancelsRevert(t *testing.T) {
+	keepLevels(t)
+	SetLevel(WARN)
+	h := LevelHandler()
+
+	serveLevel(t, h, http.MethodPut, `{"level":"debug","ttl":"50ms"}`)
+	_, state := serveLevel(t, h, http.MethodPut, `{"level":"info","ttl":"0"}`)
+	if state.RevertAt != nil {
+		t.Errorf("revert_at = %v after cancelling", state.RevertAt)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if level := GetLevel(); level != INFO {
+		t.Errorf("level = %v, want the change kept at %v", level, INFO)
+	}
+}
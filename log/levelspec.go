@@ -0,0 +1,179 @@
+package log
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// levelSpec is a parsed SetLevelSpec spec. Lookups are cached per logger
+// name, package and file, since those repeat for every call site.
+type levelSpec struct {
+	spec  string
+	rules []levelRule
+	debug bool // some rule enables DEBUG
+	cache sync.Map
+}
+
+type levelRule struct {
+	pattern string
+	level   LogLevel
+}
+
+type specKey struct {
+	name, pkg, file string
+}
+
+type levelLookup struct {
+	level   LogLevel
+	matched bool
+}
+
+var currentSpec atomic.Pointer[levelSpec]
+
+// SetLevelSpec overrides the global level for some loggers or packages. The
+// spec is a comma-separated list of pattern=level rules, for example
+//
+//	config=debug,http*=warn,*=info
+//
+// A pattern is matched against the name of a Logger created with Named, the
+// caller's package import path, the last element of that path and the
+// caller's file name without ".go". '*' matches any sequence of characters
+// and '?' any single character. The first matching rule wins; entries no
+// rule matches use the global level. An empty spec removes all overrides.
+//
+// The level of a rule replaces the global level everywhere it applies: it
+// decides whether Debug calls are made at all, and it is the threshold of
+// every sink registered without WithLevel.
+func SetLevelSpec(spec string) error {
+	parsed, err := parseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	currentSpec.Store(parsed)
+	updateLogFunctions()
+	logInternal(INFO, nil, nil, "Log level spec set to '%s'", spec)
+	return nil
+}
+
+// LevelSpec returns the spec set by SetLevelSpec, or "" if there is none.
+func LevelSpec() string {
+	if spec := currentSpec.Load(); spec != nil {
+		return spec.spec
+	}
+	return ""
+}
+
+func parseLevelSpec(spec string) (*levelSpec, error) {
+	parsed := &levelSpec{spec: spec}
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		pattern, levelStr, ok := strings.Cut(rule, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid level spec rule '%s': expected pattern=level", rule)
+		}
+		level, err := ParseLevel(strings.TrimSpace(levelStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid level spec rule '%s': %w", rule, err)
+		}
+
+		parsed.rules = append(parsed.rules, levelRule{pattern: pattern, level: level})
+		if level == DEBUG {
+			parsed.debug = true
+		}
+	}
+
+	if len(parsed.rules) == 0 {
+		return nil, nil
+	}
+	return parsed, nil
+}
+
+// levelOverride returns the level set by the current spec for a logger name,
+// caller package and caller file, if a rule matches.
+func levelOverride(name, pkg, file string) (LogLevel, bool) {
+	spec := currentSpec.Load()
+	if spec == nil {
+		return 0, false
+	}
+
+	key := specKey{name: name, pkg: pkg, file: file}
+	if cached, ok := spec.cache.Load(key); ok {
+		lookup := cached.(levelLookup)
+		return lookup.level, lookup.matched
+	}
+
+	candidates := []string{pkg, path.Base(pkg), strings.TrimSuffix(file, ".go")}
+	if name != "" {
+		candidates = append([]string{name}, candidates...)
+	}
+
+	var lookup levelLookup
+rules:
+	for _, rule := range spec.rules {
+		for _, candidate := range candidates {
+			if candidate != "" && matchPattern(rule.pattern, candidate) {
+				lookup = levelLookup{level: rule.level, matched: true}
+				break rules
+			}
+		}
+	}
+
+	spec.cache.Store(key, lookup)
+	return lookup.level, lookup.matched
+}
+
+// debugEnabled reports whether Debug calls can produce an entry anywhere,
+// either because the global level is DEBUG or because of an override.
+func debugEnabled() bool {
+	if GetLevel() >= DEBUG {
+		return true
+	}
+	spec := currentSpec.Load()
+	return spec != nil && spec.debug
+}
+
+// packageOf returns the import path of the package a function belongs to,
+// given its fully qualified name as reported by runtime.Func.Name.
+func packageOf(funcName string) string {
+	slash := strings.LastIndex(funcName, "/")
+	if dot := strings.Index(funcName[slash+1:], "."); dot >= 0 {
+		return funcName[:slash+1+dot]
+	}
+	return funcName
+}
+
+// matchPattern reports whether s matches a glob pattern in which '*' matches
+// any sequence of characters, including '/', and '?' any single character.
+func matchPattern(pattern, s string) bool {
+	p, i := 0, 0
+	star, backtrack := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, backtrack = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			backtrack++
+			i = backtrack
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
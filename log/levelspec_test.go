@@ -0,0 +1,167 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+// setLevelSpec sets spec for the rest of the test.
+func setLevelSpec(t *testing.T, spec string) {
+	t.Helper()
+	if err := SetLevelSpec(spec); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetLevelSpec("") })
+}
+
+func TestParseLevelSpec(t *testing.T) {
+	for _, tt := range []struct {
+		spec    string
+		rules   []levelRule
+		wantErr string
+	}{
+		{"", nil, ""},
+		{" , ,", nil, ""},
+		{"config=debug", []levelRule{{"config", DEBUG}}, ""},
+		{" config = debug , http*=WARN,*=info ", []levelRule{{"config", DEBUG}, {"http*", WARN}, {"*", INFO}}, ""},
+		{"config", nil, "expected pattern=level"},
+		{"=debug", nil, "expected pattern=level"},
+		{"config=loud", nil, "invalid level spec rule 'config=loud'"},
+		{"config=debug,http", nil, "invalid level spec rule 'http'"},
+	} {
+		t.Run(tt.spec, func(t *testing.T) {
+			parsed, err := parseLevelSpec(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseLevelSpec(%q) error = %v, want one containing %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rules []levelRule
+			if parsed != nil {
+				rules = parsed.rules
+			}
+			if len(rules) != len(tt.rules) {
+				t.Fatalf("rules = %v, want %v", rules, tt.rules)
+			}
+			for i := range rules {
+				if rules[i] != tt.rules[i] {
+					t.Errorf("rule %d = %v, want %v", i, rules[i], tt.rules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetLevelSpecKeepsSpecOnError(t *testing.T) {
+	setLevelSpec(t, "config=debug")
+	if err := SetLevelSpec("config=loud"); err == nil {
+		t.Fatal("SetLevelSpec accepted an invalid spec")
+	}
+	if got := LevelSpec(); got != "config=debug" {
+		t.Errorf("LevelSpec() = %q after a rejected spec, want the previous one", got)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"**", "abc", true},
+		{"http", "http", true},
+		{"http", "httpapi", false},
+		{"http*", "httpapi", true},
+		{"http*", "net/http", false},
+		{"*http", "net/http", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"?", "", false},
+		// '*' first matches too little and must backtrack.
+		{"*ab", "aab", true},
+		{"a*b", "acbcb", true},
+		{"a*b", "acbc", false},
+		{"a*b*c", "axxbyybc", true},
+		{"a*bc*d", "abcbcxd", true},
+		{"*a*b", "xaybzb", true},
+		{"*a*b", "xaybzc", false},
+		{"github.com/*/log", "github.com/acme/libs/log", true},
+	} {
+		if got := matchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLevelOverrideMatching(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		spec        string
+		logger      string
+		pkg, file   string
+		want        LogLevel
+		wantMatched bool
+	}{
+		{"logger name", "worker=debug", "worker", "example.com/app", "main.go", DEBUG, true},
+		{"nested logger name", "worker.*=debug", "worker.queue", "example.com/app", "main.go", DEBUG, true},
+		{"package path", "example.com/app/*=warn", "", "example.com/app/billing", "main.go", WARN, true},
+		{"package base", "billing=warn", "", "example.com/app/billing", "main.go", WARN, true},
+		{"file", "invoice=error", "", "example.com/app/billing", "invoice.go", ERROR, true},
+		{"no match", "billing=warn", "", "example.com/app/users", "main.go", 0, false},
+		{"unnamed logger", "worker=debug", "", "example.com/app", "main.go", 0, false},
+		// The first matching rule wins, whichever of name, package or file
+		// it matches.
+		{"name rule first", "worker=debug,billing=warn,invoice=error", "worker", "example.com/app/billing", "invoice.go", DEBUG, true},
+		{"package rule first", "billing=warn,worker=debug,invoice=error", "worker", "example.com/app/billing", "invoice.go", WARN, true},
+		{"file rule first", "invoice=error,billing=warn,worker=debug", "worker", "example.com/app/billing", "invoice.go", ERROR, true},
+		{"catch-all last", "billing=warn,*=error", "", "example.com/app/users", "main.go", ERROR, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setLevelSpec(t, tt.spec)
+			level, matched := levelOverride(tt.logger, tt.pkg, tt.file)
+			if level != tt.want || matched != tt.wantMatched {
+				t.Errorf("levelOverride(%q, %q, %q) = %v, %v, want %v, %v",
+					tt.logger, tt.pkg, tt.file, level, matched, tt.want, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func TestSetLevelSpecResetsCache(t *testing.T) {
+	setLevelSpec(t, "billing=warn")
+	if level, _ := levelOverride("", "example.com/app/billing", "main.go"); level != WARN {
+		t.Fatalf("level = %v, want %v", level, WARN)
+	}
+
+	setLevelSpec(t, "billing=error")
+	if level, _ := levelOverride("", "example.com/app/billing", "main.go"); level != ERROR {
+		t.Errorf("level after a new spec = %v, want %v", level, ERROR)
+	}
+
+	setLevelSpec(t, "")
+	if _, matched := levelOverride("", "example.com/app/billing", "main.go"); matched {
+		t.Error("override still applied after the spec was cleared")
+	}
+}
+
+func TestLevelSpecEnablesDebugForNamedLogger(t *testing.T) {
+	previous := GetLevel()
+	SetLevel(INFO)
+	t.Cleanup(func() { SetLevel(previous) })
+	setLevelSpec(t, "worker=debug")
+	sink := capture(t)
+
+	Default().Named("worker").Debug("worker detail")
+	Debug("global detail")
+	Default().Named("other").Debug("other detail")
+
+	if got := sink.messages(); len(got) != 1 || got[0] != "worker detail" {
+		t.Errorf("logged %q, want only the worker's debug entry", got)
+	}
+}
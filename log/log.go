@@ -2,11 +2,11 @@ package log
 
 import (
 	"fmt"
-	"log"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -20,7 +20,6 @@ const (
 
 var (
 	currentLevel LogLevel = INFO
-	outputMutex  sync.Mutex
 )
 
 var Info func(...any)
@@ -37,66 +36,115 @@ var Panic func(...any)
 var Panicf func(string, ...any)
 
 // updateLogFunctions re-assigns the public log functions based on the current level.
-// This is primarily to make Debug/Debugf no-ops if the level is higher.
+// This is primarily to make Debug/Debugf no-ops if neither the level nor a
+// SetLevelSpec override enables DEBUG.
 func updateLogFunctions() {
 	levelMutex.RLock()
 	lvl := currentLevel
 	levelMutex.RUnlock()
 
-	paniclnWrapper := func(format string, args ...any) {
-		log.Panicln(args...)
-	}
+	Info = std.Info
+	Warn = std.Warn
+	Error = std.Error
+	Panic = std.Panic
+	Request = std.Request
+
+	Infof = std.Infof
+	Warnf = std.Warnf
+	Errorf = std.Errorf
+	Panicf = std.Panicf
+	Requestf = std.Requestf
 
-	// --- Corrected Calls for non-f functions ---
-	Info = func(args ...any) { logInternal(INFO, nil, "%s", fmt.Sprint(args...)) }
-	Warn = func(args ...any) { logInternal(WARN, nil, "%s", fmt.Sprint(args...)) }
-	Error = func(args ...any) { logInternal(ERROR, nil, "%s", fmt.Sprint(args...)) }
-	Panic = func(args ...any) { logInternal(PANIC, paniclnWrapper, "%s", fmt.Sprint(args...)) }
-	Request = func(args ...any) { logInternal(REQUEST, nil, "%s", fmt.Sprint(args...)) }
-
-	// --- Calls for -f functions remain the same ---
-	Infof = func(format string, args ...any) { logInternal(INFO, nil, format, args...) }
-	Warnf = func(format string, args ...any) { logInternal(WARN, nil, format, args...) }
-	Errorf = func(format string, args ...any) { logInternal(ERROR, nil, format, args...) }
-	Panicf = func(format string, args ...any) { logInternal(PANIC, log.Panicf, format, args...) }
-	Requestf = func(format string, args ...any) { logInternal(REQUEST, nil, format, args...) }
-
-	if lvl >= DEBUG {
-		Debug = func(args ...any) { logInternal(DEBUG, nil, "%s", fmt.Sprint(args...)) }
-		Debugf = func(format string, args ...any) { logInternal(DEBUG, nil, format, args...) }
+	if spec := currentSpec.Load(); lvl >= DEBUG || (spec != nil && spec.debug) {
+		Debug = std.Debug
+		Debugf = std.Debugf
 	} else {
 		Debug = func(...any) {}
 		Debugf = func(string, ...any) {}
 	}
 }
 
-func findCaller() (string, int) {
+type packageMarker struct{}
+
+// loggerPackagePath is the import path of this package, used by findCaller
+// to skip the logger's own frames.
+var loggerPackagePath = reflect.TypeOf(packageMarker{}).PkgPath()
+
+// findCaller returns the file name, line and package import path of the
+// first caller outside this package.
+func findCaller() (string, int, string) {
 	var file string
 	var line int
 	var ok bool
-	const maxStackDepth = 10
-	loggerPackagePath := "github.com/lunarhue/go-stack/libs/log"
+	const maxStackDepth = 16
 
-	for skip := 3; skip < maxStackDepth; skip++ {
+	for skip := 2; skip < maxStackDepth; skip++ {
 		var pc uintptr
 		pc, file, line, ok = runtime.Caller(skip)
 		if !ok {
-			return "???", 0
+			return "???", 0, ""
 		}
 
 		fn := runtime.FuncForPC(pc)
 		if fn != nil {
 			funcName := fn.Name()
-			if !strings.HasPrefix(funcName, loggerPackagePath) {
-				return filepath.Base(file), line
+			if !strings.HasPrefix(funcName, loggerPackagePath+".") {
+				return filepath.Base(file), line, packageOf(funcName)
 			}
 		} else {
 			if !strings.Contains(file, loggerPackagePath) {
-				return filepath.Base(file), line
+				return filepath.Base(file), line, ""
 			}
 		}
 	}
-	return "???", 0
+	return "???", 0, ""
+}
+
+// Entry is a single log record as handed to formatLog and encoders.
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	Fields  []Field
+	File    string
+	Line    int
+
+	// template is the format string or slog message the entry was created
+	// from, used to group entries for sampling.
+	template string
+
+	// pkg is the import path of the package that logged the entry, which
+	// with File and Line identifies its call site.
+	pkg string
+
+	// levelOverride is the level set by SetLevelSpec for the entry's logger
+	// or package, used instead of the global level when hasOverride is set.
+	levelOverride LogLevel
+	hasOverride   bool
+
+	// prefixLen is the length of the command prefix at the start of the
+	// message of entries logged by LogCommand, painted prefixColor on
+	// coloured consoles.
+	prefixLen   int
+	prefixColor string
+}
+
+// paintMessage returns the entry's message with its command prefix, if
+// any, coloured. A nil theme leaves it uncoloured.
+func paintMessage(entry *Entry, theme *Theme) string {
+	if theme == nil || entry.prefixColor == "" || entry.prefixLen == 0 {
+		return entry.Message
+	}
+	return paint(entry.prefixColor, entry.Message[:entry.prefixLen]) + entry.Message[entry.prefixLen:]
+}
+
+// threshold returns the most verbose level the entry is written at by sinks
+// that follow the global level.
+func (e *Entry) threshold() LogLevel {
+	if e.hasOverride {
+		return e.levelOverride
+	}
+	return GetLevel()
 }
 
 type Destination int
@@ -108,54 +156,76 @@ const (
 	FILE
 )
 
-func formatLog(
-	dest Destination,
-	level LogLevel,
-	time *time.Time,
-	message string,
-) string {
-	timestamp := time.Local().Format("2006-01-02 15:04:05")
-	levelStr := levelNames[level]
-	levelColor := levelColors[level]
+// formatFields renders fields as " key=value" pairs, quoting values that
+// contain whitespace, quotes or '='. Keys are painted with keyColor, if any.
+func formatFields(fields []Field, keyColor string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteByte(' ')
+		if keyColor != "" {
+			b.WriteString(keyColor)
+			b.WriteString(field.Key)
+			b.WriteString("=")
+			b.WriteString(colorReset)
+		} else {
+			b.WriteString(field.Key)
+			b.WriteByte('=')
+		}
+		b.WriteString(formatFieldValue(field.Value))
+	}
+	return b.String()
+}
+
+func formatFieldValue(value any) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// formatLog renders an entry for dest. The console destinations are
+// coloured with theme; a nil theme renders them as plain text.
+func formatLog(dest Destination, entry *Entry, theme *Theme) string {
+	timestamp := entry.Time.Local().Format("2006-01-02 15:04:05")
+	levelStr := entry.Level.String()
 
 	switch dest {
 	case STDOUT:
 		return fmt.Sprintf(
-			"%s%s %s%s%s: %s",
-			colorDarkGrey, timestamp,
-			levelColor, levelStr, colorReset,
-			message,
+			"%s %s: %s%s",
+			paint(theme.timestamp(), timestamp),
+			paint(theme.level(entry.Level), levelStr),
+			paintMessage(entry, theme), formatFields(entry.Fields, theme.fieldKey()),
 		)
 	case STDOUT_DEBUG:
-		file, line := findCaller()
-
 		return fmt.Sprintf(
-			"%s%s %s:%d %s%s%s: %s",
-			colorDarkGrey, timestamp,
-			file, line,
-			levelColor, levelStr, colorReset,
-			message,
+			"%s %s: %s%s",
+			paint(theme.timestamp(), fmt.Sprintf("%s %s:%d", timestamp, entry.File, entry.Line)),
+			paint(theme.level(entry.Level), levelStr),
+			paintMessage(entry, theme), formatFields(entry.Fields, theme.fieldKey()),
 		)
 	case FILE:
-		timestamp := time.UTC().Format("2006-01-02 15:04:05")
-		file, line := findCaller()
+		timestamp := entry.Time.UTC().Format("2006-01-02 15:04:05")
 
 		return fmt.Sprintf(
-			"%s %s:%d %s: %s",
+			"%s %s:%d %s: %s%s",
 			timestamp,
-			file, line,
+			entry.File, entry.Line,
 			levelStr,
-			message,
+			entry.Message, formatFields(entry.Fields, ""),
 		)
 	case STDERR:
-		file, line := findCaller()
-
 		return fmt.Sprintf(
-			"%s %s:%d %s: %s",
+			"%s %s:%d %s: %s%s",
 			timestamp,
-			file, line,
+			entry.File, entry.Line,
 			levelStr,
-			message,
+			entry.Message, formatFields(entry.Fields, ""),
 		)
 	default:
 		panic(fmt.Sprintf("Unknown destination: %d", dest))
@@ -163,51 +233,44 @@ func formatLog(
 }
 
 // logInternal is the central function that handles formatting and output.
-func logInternal(level LogLevel, panicFunc func(string, ...interface{}), format string, args ...interface{}) {
-	currTime := time.Now().Local()
-	message := fmt.Sprintf(format, args...)
-
-	// --- File Logging (Always, No Deduplication) ---
-	fileLog := formatLog(FILE, level, &currTime, message)
-	logToFile(fileLog)
-
-	// --- Console Logging (Level Filtered + Deduplication) ---
-	consoleLevel := GetLevel()
-	effectiveLevel := level
-	if level == REQUEST {
-		effectiveLevel = INFO
-	}
+// A nil logger logs without fields or name.
+func logInternal(level LogLevel, logger *Logger, panicFunc func(string, ...interface{}), format string, args ...interface{}) {
+	file, line, pkg := findCaller()
+	logAt(file, line, pkg, level, logger, panicFunc, format, args...)
+}
 
-	// Filter out levels higher than the current level
-	if effectiveLevel > consoleLevel {
-		goto HandlePanic
+// logAt is logInternal with an explicit caller, for entries the package
+// logs on behalf of other code, where findCaller would only find net/http or
+// the runtime.
+func logAt(file string, line int, pkg string, level LogLevel, logger *Logger, panicFunc func(string, ...interface{}), format string, args ...interface{}) {
+	if logger == nil {
+		logger = std
 	}
 
-	// --- Deduplication Logic ---
-	{
-		var dest Destination
-		if currentLevel == DEBUG {
-			dest = STDOUT_DEBUG
-		} else {
-			dest = STDOUT
-		}
-
-		if dedupConsoleLog(dest, level, message) {
-			fmt.Print("\033[F") // Move cursor up one line
-			fmt.Print("\r")     // Move to the beginning of that line
-			fmt.Print("\033[K") // Clear that line
-			fmt.Printf("%s %s(%dx)%s\n", formatLog(dest, level, &currTime, message), colorYellow, latestCounter, colorReset)
+	message := fmt.Sprintf(format, args...)
+	entry := &Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: message,
+		Fields:  withStack(level, traceFields(logger.span, logger.fields)),
+		File:    file,
+		Line:    line,
 
-			goto HandlePanic
-		}
+		template: format,
+		pkg:      pkg,
+	}
+	if format == "%s" {
+		entry.template = message
+	}
+	entry.levelOverride, entry.hasOverride = levelOverride(logger.name, pkg, file)
+	addSpanEvent(logger.span, entry)
 
-		// --- Output the Console Log Lines ---
-		outputMutex.Lock()
-		fmt.Println(formatLog(dest, level, &currTime, message))
-		defer outputMutex.Unlock()
+	// Debug calls are only made when some override enables DEBUG, so entries
+	// from everywhere else are dropped here, before reaching any sink.
+	if level != DEBUG || entry.threshold() >= DEBUG {
+		dispatch(entry)
 	}
 
-HandlePanic:
 	// --- Handle Panic (After Logging) ---
 	if level == PANIC && panicFunc != nil {
 		panicFunc(format, args...)
@@ -216,6 +279,16 @@ HandlePanic:
 	}
 }
 
+// dispatch writes an entry to every registered sink unless deduplication
+// or sampling drops it. It never panics on PANIC entries; that is left to
+// the caller.
+func dispatch(entry *Entry) {
+	if !dedupEntry(entry) || !sampleEntry(entry) {
+		return
+	}
+	deliver(entry)
+}
+
 func init() {
 	updateLogFunctions()
 }
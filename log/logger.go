@@ -0,0 +1,151 @@
+package log
+
+import (
+	"fmt"
+	"log"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Field is a single structured key/value pair attached to a log entry.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Logger writes entries through the package's shared outputs while carrying
+// its own set of structured fields. The zero value is ready to use.
+type Logger struct {
+	name   string
+	fields []Field
+	span   trace.Span // set by WithContext
+}
+
+var std = &Logger{}
+
+// Default returns the logger used by the package-level functions.
+func Default() *Logger {
+	return std
+}
+
+// New returns a logger carrying the given key/value pairs.
+func New(keyvals ...any) *Logger {
+	return std.With(keyvals...)
+}
+
+// With returns a child logger that carries the parent's fields plus the
+// given key/value pairs. Keys that are not strings are formatted with
+// fmt.Sprint; a trailing value without a key is stored under "!BADKEY".
+func (l *Logger) With(keyvals ...any) *Logger {
+	if len(keyvals) == 0 {
+		return l
+	}
+
+	fields := make([]Field, len(l.fields), len(l.fields)+len(keyvals)/2+1)
+	copy(fields, l.fields)
+	fields = appendKeyvals(fields, keyvals)
+
+	return &Logger{name: l.name, fields: fields, span: l.span}
+}
+
+// Named returns a child logger with name appended to the parent's name,
+// separated by a dot. Names are matched by SetLevelSpec.
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	return &Logger{name: name, fields: l.fields, span: l.span}
+}
+
+// Name returns the logger's name, or "" for an unnamed logger.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// Fields returns a copy of the fields carried by the logger.
+func (l *Logger) Fields() []Field {
+	return append([]Field(nil), l.fields...)
+}
+
+func appendKeyvals(fields []Field, keyvals []any) []Field {
+	for i := 0; i < len(keyvals); i++ {
+		switch key := keyvals[i].(type) {
+		case Field:
+			fields = append(fields, key)
+			continue
+		case string:
+			if i+1 < len(keyvals) {
+				fields = append(fields, Field{Key: key, Value: keyvals[i+1]})
+				i++
+				continue
+			}
+		default:
+			if i+1 < len(keyvals) {
+				fields = append(fields, Field{Key: fmt.Sprint(key), Value: keyvals[i+1]})
+				i++
+				continue
+			}
+		}
+		fields = append(fields, Field{Key: "!BADKEY", Value: keyvals[i]})
+	}
+	return fields
+}
+
+func (l *Logger) Info(args ...any) {
+	logInternal(INFO, l, nil, "%s", fmt.Sprint(args...))
+}
+
+func (l *Logger) Infof(format string, args ...any) {
+	logInternal(INFO, l, nil, format, args...)
+}
+
+func (l *Logger) Request(args ...any) {
+	logInternal(REQUEST, l, nil, "%s", fmt.Sprint(args...))
+}
+
+func (l *Logger) Requestf(format string, args ...any) {
+	logInternal(REQUEST, l, nil, format, args...)
+}
+
+func (l *Logger) Warn(args ...any) {
+	logInternal(WARN, l, nil, "%s", fmt.Sprint(args...))
+}
+
+func (l *Logger) Warnf(format string, args ...any) {
+	logInternal(WARN, l, nil, format, args...)
+}
+
+func (l *Logger) Error(args ...any) {
+	logInternal(ERROR, l, nil, "%s", fmt.Sprint(args...))
+}
+
+func (l *Logger) Errorf(format string, args ...any) {
+	logInternal(ERROR, l, nil, format, args...)
+}
+
+// Debug is a no-op unless the current level, or the SetLevelSpec override
+// for the logger or caller, is DEBUG.
+func (l *Logger) Debug(args ...any) {
+	if debugEnabled() {
+		logInternal(DEBUG, l, nil, "%s", fmt.Sprint(args...))
+	}
+}
+
+// Debugf is a no-op unless the current level, or the SetLevelSpec override
+// for the logger or caller, is DEBUG.
+func (l *Logger) Debugf(format string, args ...any) {
+	if debugEnabled() {
+		logInternal(DEBUG, l, nil, format, args...)
+	}
+}
+
+func (l *Logger) Panic(args ...any) {
+	paniclnWrapper := func(string, ...any) {
+		log.Panicln(args...)
+	}
+	logInternal(PANIC, l, paniclnWrapper, "%s", fmt.Sprint(args...))
+}
+
+func (l *Logger) Panicf(format string, args ...any) {
+	logInternal(PANIC, l, log.Panicf, format, args...)
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"slices"
+	"testing"
+)
+
+type stringerKey struct{}
+
+func (k stringerKey) String() string { return "key" }
+
+func TestAppendKeyvals(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		keyvals []any
+		want    []Field
+	}{
+		{"pairs", []any{"a", 1, "b", "two"}, []Field{{Key: "a", Value: 1}, {Key: "b", Value: "two"}}},
+		{"field", []any{Field{Key: "f", Value: 1}, "a", 2}, []Field{{Key: "f", Value: 1}, {Key: "a", Value: 2}}},
+		{"int key", []any{42, "answer"}, []Field{{Key: "42", Value: "answer"}}},
+		{"stringer key", []any{stringerKey{}, true}, []Field{{Key: "key", Value: true}}},
+		{"nil key", []any{nil, "v"}, []Field{{Key: "<nil>", Value: "v"}}},
+		{"trailing value", []any{"a", 1, "orphan"}, []Field{{Key: "a", Value: 1}, {Key: "!BADKEY", Value: "orphan"}}},
+		{"trailing non-string", []any{"a", 1, 3.5}, []Field{{Key: "a", Value: 1}, {Key: "!BADKEY", Value: 3.5}}},
+		{"field after pair", []any{"a", Field{Key: "f", Value: 1}}, []Field{{Key: "a", Value: Field{Key: "f", Value: 1}}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendKeyvals(nil, tt.keyvals); !slices.Equal(got, tt.want) {
+				t.Errorf("appendKeyvals(%v) = %v, want %v", tt.keyvals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerWith(t *testing.T) {
+	parent := New("service", "api")
+	if got := parent.With(); got != parent {
+		t.Error("With() returned a new logger")
+	}
+
+	// Two children of the same parent must not share the parent's spare
+	// capacity.
+	a := parent.With("user", "ada")
+	b := parent.With("user", "bob")
+	for _, tt := range []struct {
+		logger *Logger
+		want   []Field
+	}{
+		{parent, []Field{{Key: "service", Value: "api"}}},
+		{a, []Field{{Key: "service", Value: "api"}, {Key: "user", Value: "ada"}}},
+		{b, []Field{{Key: "service", Value: "api"}, {Key: "user", Value: "bob"}}},
+		{a.With("attempt", 2), []Field{{Key: "service", Value: "api"}, {Key: "user", Value: "ada"}, {Key: "attempt", Value: 2}}},
+	} {
+		if got := tt.logger.Fields(); !slices.Equal(got, tt.want) {
+			t.Errorf("fields = %v, want %v", got, tt.want)
+		}
+	}
+
+	// Fields returns a copy.
+	fields := a.Fields()
+	fields[0].Value = "changed"
+	if got := a.Fields()[0].Value; got != "api" {
+		t.Errorf("changing the returned fields changed the logger: %v", got)
+	}
+}
+
+func TestLoggerNamed(t *testing.T) {
+	worker := New("service", "api").Named("worker")
+	queue := worker.Named("queue").With("queue", "emails")
+
+	if got := worker.Name(); got != "worker" {
+		t.Errorf("Name() = %q, want %q", got, "worker")
+	}
+	if got := queue.Name(); got != "worker.queue" {
+		t.Errorf("Name() = %q, want %q", got, "worker.queue")
+	}
+	want := []Field{{Key: "service", Value: "api"}, {Key: "queue", Value: "emails"}}
+	if got := queue.Fields(); !slices.Equal(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	if got := Default().Name(); got != "" {
+		t.Errorf("Default().Name() = %q", got)
+	}
+}
+
+func TestLoggerFieldsOnEntries(t *testing.T) {
+	sink := capture(t)
+	New("service", "api").Named("worker").With(7, "seven", "orphan").Warn("inherited")
+
+	entries := sink.all()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	want := []Field{{Key: "service", Value: "api"}, {Key: "7", Value: "seven"}, {Key: "!BADKEY", Value: "orphan"}}
+	if got := entries[0].Fields; !slices.Equal(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
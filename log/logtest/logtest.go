@@ -0,0 +1,267 @@
+// Package logtest captures the entries written through the log package so
+// tests can assert on them.
+//
+//	func TestLoad(t *testing.T) {
+//		rec := logtest.New(t, logtest.WithTestLog())
+//		load()
+//		rec.AssertLogged(logtest.Level(log.WARN), logtest.Field("path", "config.yaml"))
+//	}
+//
+// The recorder is registered as a log sink for the lifetime of the test. Sinks
+// are global, so a recorder also sees entries logged by parallel tests.
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lunarhue/libs-go/log"
+)
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithTestLog forwards every captured entry to t.Log, so the output of a
+// failing test includes what was logged.
+func WithTestLog() Option {
+	return func(r *Recorder) {
+		r.forward = true
+	}
+}
+
+// WithEncoder sets the encoder used for the lines forwarded to t.Log.
+// Defaults to log.TextEncoder.
+func WithEncoder(encoder log.Encoder) Option {
+	return func(r *Recorder) {
+		r.encoder = encoder
+	}
+}
+
+// Recorder is a log.Sink that keeps every entry written to it.
+type Recorder struct {
+	t       testing.TB
+	name    string
+	forward bool
+	encoder log.Encoder
+
+	mu      sync.Mutex
+	entries []*log.Entry
+	closed  bool
+}
+
+var recorders atomic.Uint64
+
+// New registers a Recorder that captures entries at every level until the
+// test finishes. Debug entries are only produced when the log level, or a
+// log.SetLevelSpec override, enables them.
+func New(t testing.TB, opts ...Option) *Recorder {
+	t.Helper()
+
+	r := &Recorder{
+		t:       t,
+		name:    fmt.Sprintf("logtest-%d", recorders.Add(1)),
+		encoder: log.TextEncoder,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	log.AddSink(r.name, r, log.WithLevel(log.DEBUG), log.WithEncoder(r.encoder))
+	t.Cleanup(func() {
+		log.Flush(context.Background())
+		log.RemoveSink(r.name)
+	})
+	return r
+}
+
+func (r *Recorder) Write(entry *log.Entry, line string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.entries = append(r.entries, entry)
+	if r.forward {
+		r.t.Log(line)
+	}
+	return nil
+}
+
+func (r *Recorder) Flush() error {
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	return nil
+}
+
+// Entries returns the captured entries, oldest first. Entries queued by
+// log.EnableAsync are written before returning.
+func (r *Recorder) Entries() []*log.Entry {
+	log.Flush(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*log.Entry(nil), r.entries...)
+}
+
+// Reset forgets the captured entries.
+func (r *Recorder) Reset() {
+	log.Flush(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// Find returns the captured entries that satisfy every matcher.
+func (r *Recorder) Find(matchers ...Matcher) []*log.Entry {
+	var found []*log.Entry
+	for _, entry := range r.Entries() {
+		if matchAll(entry, matchers) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// AssertLogged fails the test unless an entry satisfies every matcher, and
+// returns the first such entry, or nil.
+func (r *Recorder) AssertLogged(matchers ...Matcher) *log.Entry {
+	r.t.Helper()
+
+	found := r.Find(matchers...)
+	if len(found) == 0 {
+		r.t.Errorf("no entry %s\n%s", describe(matchers), r.dump())
+		return nil
+	}
+	return found[0]
+}
+
+// AssertNotLogged fails the test if an entry satisfies every matcher.
+func (r *Recorder) AssertNotLogged(matchers ...Matcher) {
+	r.t.Helper()
+
+	if found := r.Find(matchers...); len(found) > 0 {
+		r.t.Errorf("unexpected entry %s\n%s", describe(matchers), r.dump())
+	}
+}
+
+// AssertCount fails the test unless exactly n entries satisfy every matcher.
+func (r *Recorder) AssertCount(n int, matchers ...Matcher) {
+	r.t.Helper()
+
+	if found := r.Find(matchers...); len(found) != n {
+		r.t.Errorf("got %d entries %s, want %d\n%s", len(found), describe(matchers), n, r.dump())
+	}
+}
+
+// dump lists the captured entries for failure messages.
+func (r *Recorder) dump() string {
+	entries := r.Entries()
+	if len(entries) == 0 {
+		return "captured no entries"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "captured %d entries:", len(entries))
+	for _, entry := range entries {
+		b.WriteString("\n\t")
+		b.WriteString(log.TextEncoder.Encode(entry))
+	}
+	return b.String()
+}
+
+// Matcher selects entries for Find and the assertions.
+type Matcher struct {
+	description string
+	match       func(entry *log.Entry) bool
+}
+
+// Match returns a matcher using a custom predicate, described by description
+// in failure messages.
+func Match(description string, match func(entry *log.Entry) bool) Matcher {
+	return Matcher{description: description, match: match}
+}
+
+// Level matches entries at exactly level.
+func Level(level log.LogLevel) Matcher {
+	return Match(fmt.Sprintf("at level %s", level), func(entry *log.Entry) bool {
+		return entry.Level == level
+	})
+}
+
+// Message matches entries whose message is exactly message.
+func Message(message string) Matcher {
+	return Match(fmt.Sprintf("with message %q", message), func(entry *log.Entry) bool {
+		return entry.Message == message
+	})
+}
+
+// MessageContains matches entries whose message contains substr.
+func MessageContains(substr string) Matcher {
+	return Match(fmt.Sprintf("with message containing %q", substr), func(entry *log.Entry) bool {
+		return strings.Contains(entry.Message, substr)
+	})
+}
+
+// HasField matches entries carrying a field named key.
+func HasField(key string) Matcher {
+	return Match(fmt.Sprintf("with field %s", key), func(entry *log.Entry) bool {
+		_, ok := fieldValue(entry, key)
+		return ok
+	})
+}
+
+// Field matches entries carrying a field named key whose value equals value,
+// either deeply or in its fmt representation, so that 1 matches int64(1).
+func Field(key string, value any) Matcher {
+	return Match(fmt.Sprintf("with field %s=%v", key, value), func(entry *log.Entry) bool {
+		actual, ok := fieldValue(entry, key)
+		if !ok {
+			return false
+		}
+		return reflect.DeepEqual(actual, value) || fmt.Sprint(actual) == fmt.Sprint(value)
+	})
+}
+
+// fieldValue returns the value of the last field named key, which is the
+// one that wins when a logger's field is repeated by a child.
+func fieldValue(entry *log.Entry, key string) (any, bool) {
+	for i := len(entry.Fields) - 1; i >= 0; i-- {
+		if entry.Fields[i].Key == key {
+			return entry.Fields[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+func matchAll(entry *log.Entry, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.match(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return "at all"
+	}
+	descriptions := make([]string, len(matchers))
+	for i, matcher := range matchers {
+		descriptions[i] = matcher.description
+	}
+	return strings.Join(descriptions, " and ")
+}
@@ -0,0 +1,139 @@
+package logtest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lunarhue/libs-go/log"
+	"github.com/lunarhue/libs-go/log/logtest"
+)
+
+// fakeT records the failures and log output of assertions made through it,
+// so failing assertions can be tested without failing the test.
+type fakeT struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Log(args ...any) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func TestMatchers(t *testing.T) {
+	rec := logtest.New(t)
+	log.New("path", "config.yaml", "attempt", 1).Warn("config not found, using defaults")
+	log.Info("matchers unrelated")
+
+	for _, tt := range []struct {
+		name     string
+		matchers []logtest.Matcher
+		want     int
+	}{
+		{"level", []logtest.Matcher{logtest.Level(log.WARN)}, 1},
+		{"other level", []logtest.Matcher{logtest.Level(log.ERROR)}, 0},
+		{"message", []logtest.Matcher{logtest.Message("config not found, using defaults")}, 1},
+		{"partial message", []logtest.Matcher{logtest.Message("config not found")}, 0},
+		{"message contains", []logtest.Matcher{logtest.MessageContains("not found")}, 1},
+		{"has field", []logtest.Matcher{logtest.HasField("path")}, 1},
+		{"missing field", []logtest.Matcher{logtest.HasField("user")}, 0},
+		{"field", []logtest.Matcher{logtest.Field("path", "config.yaml")}, 1},
+		{"field of another type", []logtest.Matcher{logtest.Field("attempt", int64(1))}, 1},
+		{"field with another value", []logtest.Matcher{logtest.Field("path", "other.yaml")}, 0},
+		{"all", []logtest.Matcher{logtest.Level(log.WARN), logtest.HasField("attempt")}, 1},
+		{"not all", []logtest.Matcher{logtest.Level(log.INFO), logtest.HasField("attempt")}, 0},
+		{"custom", []logtest.Matcher{logtest.Match("unrelated", func(entry *log.Entry) bool {
+			return strings.HasPrefix(entry.Message, "matchers ")
+		})}, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(rec.Find(tt.matchers...)); got != tt.want {
+				t.Errorf("Find() matched %d entries, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	ft := &fakeT{TB: t}
+	rec := logtest.New(ft)
+	log.Error("assertions failed once")
+
+	if entry := rec.AssertLogged(logtest.Level(log.ERROR)); entry == nil || entry.Message != "assertions failed once" {
+		t.Errorf("AssertLogged() = %v", entry)
+	}
+	rec.AssertNotLogged(logtest.Level(log.PANIC))
+	rec.AssertCount(1, logtest.MessageContains("assertions"))
+	if len(ft.errors) != 0 {
+		t.Fatalf("passing assertions failed: %q", ft.errors)
+	}
+
+	if entry := rec.AssertLogged(logtest.Level(log.REQUEST), logtest.Message("missing")); entry != nil {
+		t.Errorf("AssertLogged() = %v, want nil", entry)
+	}
+	rec.AssertNotLogged(logtest.Level(log.ERROR))
+	rec.AssertCount(2, logtest.Level(log.ERROR))
+
+	want := []string{
+		`no entry at level REQ and with message "missing"`,
+		"unexpected entry at level ERROR",
+		"got 1 entries at level ERROR, want 2",
+	}
+	if len(ft.errors) != len(want) {
+		t.Fatalf("failures = %q, want %d", ft.errors, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(ft.errors[i], prefix+"\n") || !strings.Contains(ft.errors[i], "assertions failed once") {
+			t.Errorf("failure %d = %q, want %q followed by the captured entries", i, ft.errors[i], prefix)
+		}
+	}
+
+	rec.Reset()
+	if entries := rec.Entries(); len(entries) != 0 {
+		t.Errorf("Entries() after Reset = %d entries", len(entries))
+	}
+}
+
+func TestWithTestLog(t *testing.T) {
+	ft := &fakeT{TB: t}
+	logtest.New(ft, logtest.WithTestLog(), logtest.WithEncoder(log.JSONEncoder))
+	log.Warn("forwarded to the test log")
+
+	if len(ft.logs) != 1 || !strings.Contains(ft.logs[0], `"msg":"forwarded to the test log"`) {
+		t.Errorf("test log = %q", ft.logs)
+	}
+}
+
+func TestCleanupRemovesSink(t *testing.T) {
+	recorders := func() int {
+		n := 0
+		for _, name := range log.SinkNames() {
+			if strings.HasPrefix(name, "logtest-") {
+				n++
+			}
+		}
+		return n
+	}
+	before := recorders()
+
+	var rec *logtest.Recorder
+	t.Run("inner", func(t *testing.T) {
+		rec = logtest.New(t)
+		if got := recorders(); got != before+1 {
+			t.Errorf("%d recorders registered, want %d", got, before+1)
+		}
+	})
+
+	if got := recorders(); got != before {
+		t.Errorf("%d recorders registered after the test, want %d", got, before)
+	}
+	log.Info("after cleanup")
+	if found := rec.Find(logtest.Message("after cleanup")); len(found) != 0 {
+		t.Error("recorder captured an entry after its test ended")
+	}
+}
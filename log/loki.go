@@ -0,0 +1,511 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LokiConfig configures a LokiSink.
+type LokiConfig struct {
+	// URL is the push endpoint, e.g. "http://localhost:3100/loki/api/v1/push".
+	URL      string
+	TenantID string      // sent as X-Scope-OrgID when set
+	Header   http.Header // added to every request
+	Client   *http.Client
+
+	// Labels are added to every stream. Entries also get a "level" label
+	// unless Labels or EntryLabels set one.
+	Labels map[string]string
+	// EntryLabels returns additional labels for an entry. Keep their
+	// cardinality low, since Loki creates a stream per label set.
+	EntryLabels func(entry *Entry) map[string]string
+
+	// BatchSize is the number of entries that triggers a push. Defaults to 1000.
+	BatchSize int
+	// BatchWait is the longest an entry waits for its batch to fill.
+	// Defaults to 1s.
+	BatchWait time.Duration
+	// MaxPending bounds the entries held in memory; the oldest are dropped
+	// beyond it. Defaults to 10 * BatchSize.
+	MaxPending int
+	Compress   bool // gzip request bodies
+
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries of a failed push. Default to 500ms and 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxRetries is the number of retries before a batch is spooled or
+	// dropped. Defaults to 5; negative disables retries.
+	MaxRetries int
+
+	// SpoolDir, if set, keeps batches that could not be pushed on disk.
+	// While the endpoint is down new batches go straight to the spool, and
+	// the spool is retried every MaxBackoff and sent oldest first once the
+	// endpoint is back. Spooled batches left by a previous process are sent
+	// too.
+	SpoolDir string
+	// MaxSpoolSize bounds the spool in bytes; the oldest batches are deleted
+	// beyond it. Defaults to 100MB.
+	MaxSpoolSize int64
+}
+
+// LokiSink ships entries to a Loki-compatible push endpoint in batches.
+// Lines are produced by the sink's encoder; LogfmtEncoder or JSONEncoder let
+// Loki parse the fields.
+type LokiSink struct {
+	config LokiConfig
+
+	mu      sync.Mutex
+	pending []lokiEntry
+	dropped int
+	full    chan struct{}
+
+	flush chan chan error
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	// Only used by the shipping goroutine.
+	down      bool
+	nextProbe time.Time
+}
+
+type lokiEntry struct {
+	labels map[string]string
+	time   time.Time
+	line   string
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiStatusError is a push rejected by the endpoint.
+type lokiStatusError struct {
+	status string
+	code   int
+	body   string
+}
+
+func (e *lokiStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s: %s", e.status, e.body)
+}
+
+// retryable reports whether the push may succeed later. Other client
+// errors mean the batch itself was rejected.
+func (e *lokiStatusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// NewLokiSink validates the configuration, creates the spool directory and
+// starts shipping.
+//
+//	sink, err := log.NewLokiSink(log.LokiConfig{
+//		URL:    "http://localhost:3100/loki/api/v1/push",
+//		Labels: map[string]string{"app": "api"},
+//	})
+//	log.AddSink("loki", sink, log.WithEncoder(log.LogfmtEncoder))
+func NewLokiSink(config LokiConfig) (*LokiSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("loki push URL is required")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.BatchWait <= 0 {
+		config.BatchWait = time.Second
+	}
+	if config.MaxPending < config.BatchSize {
+		config.MaxPending = 10 * config.BatchSize
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = max(30*time.Second, config.MinBackoff)
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	}
+	if config.MaxSpoolSize <= 0 {
+		config.MaxSpoolSize = 100 << 20
+	}
+	if config.SpoolDir != "" {
+		if err := os.MkdirAll(config.SpoolDir, 0750); err != nil {
+			return nil, fmt.Errorf("failed to create loki spool directory '%s': %w", config.SpoolDir, err)
+		}
+	}
+
+	s := &LokiSink{
+		config: config,
+		full:   make(chan struct{}, 1),
+		flush:  make(chan chan error),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *LokiSink) Write(entry *Entry, line string) error {
+	labels := make(map[string]string, len(s.config.Labels)+1)
+	labels["level"] = strings.ToLower(entry.Level.String())
+	for name, value := range s.config.Labels {
+		labels[lokiLabelName(name)] = value
+	}
+	if s.config.EntryLabels != nil {
+		for name, value := range s.config.EntryLabels(entry) {
+			labels[lokiLabelName(name)] = value
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, lokiEntry{labels: labels, time: entry.Time, line: line})
+	if over := len(s.pending) - s.config.MaxPending; over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+	}
+	if len(s.pending) >= s.config.BatchSize {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush pushes the pending entries, spooling or dropping them if the
+// endpoint cannot be reached.
+func (s *LokiSink) Flush() error {
+	result := make(chan error, 1)
+	select {
+	case s.flush <- result:
+		return <-result
+	case <-s.done:
+		return nil
+	}
+}
+
+// Close pushes the pending entries and stops shipping.
+func (s *LokiSink) Close() error {
+	err := s.Flush()
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return err
+}
+
+func (s *LokiSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.BatchWait)
+	defer ticker.Stop()
+
+	// Send what a previous process left in the spool.
+	s.drainSpool()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.down && !time.Now().Before(s.nextProbe) {
+				s.drainSpool()
+			}
+			s.ship(false)
+		case <-s.full:
+			s.ship(false)
+		case result := <-s.flush:
+			result <- s.ship(true)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// ship pushes the pending entries in batches of at most BatchSize. Errors
+// are returned when report is set, for Flush, and printed otherwise.
+func (s *LokiSink) ship(report bool) error {
+	var errs []error
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), s.config.BatchSize)
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		dropped := s.dropped
+		s.dropped = 0
+		s.mu.Unlock()
+
+		if dropped > 0 {
+			reportError("Dropped %d log entries for loki %s because too many were pending", dropped, s.config.URL)
+		}
+		if len(batch) == 0 {
+			return errors.Join(errs...)
+		}
+		if err := s.shipBatch(batch); err != nil {
+			errs = append(errs, err)
+			if !report {
+				reportError("%v", err)
+			}
+		}
+	}
+}
+
+func (s *LokiSink) shipBatch(batch []lokiEntry) error {
+	body, err := s.encode(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode %d log entries for loki: %w", len(batch), err)
+	}
+
+	if s.down && s.config.SpoolDir != "" {
+		return s.spool(body)
+	}
+
+	if err := s.pushWithRetry(body); err != nil {
+		var status *lokiStatusError
+		if s.config.SpoolDir == "" || (errors.As(err, &status) && !status.retryable()) {
+			return fmt.Errorf("failed to push %d log entries to loki '%s': %w", len(batch), s.config.URL, err)
+		}
+		s.down = true
+		s.nextProbe = time.Now().Add(s.config.MaxBackoff)
+		reportError("Failed to push log entries to loki %s, spooling until it is back: %v", s.config.URL, err)
+		return s.spool(body)
+	}
+
+	s.drainSpool()
+	return nil
+}
+
+// encode builds a push request body, with one stream per label set.
+func (s *LokiSink) encode(batch []lokiEntry) ([]byte, error) {
+	streams := map[string]*lokiStream{}
+	var keys []string
+	for _, entry := range batch {
+		key := lokiStreamKey(entry.labels)
+		stream := streams[key]
+		if stream == nil {
+			stream = &lokiStream{Stream: entry.labels}
+			streams[key] = stream
+			keys = append(keys, key)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(entry.time.UnixNano(), 10), entry.line})
+	}
+
+	push := lokiPush{Streams: make([]lokiStream, len(keys))}
+	for i, key := range keys {
+		push.Streams[i] = *streams[key]
+	}
+
+	data, err := json.Marshal(push)
+	if err != nil || !s.config.Compress {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *LokiSink) pushWithRetry(body []byte) error {
+	backoff := s.config.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.push(body, s.config.Compress)
+		if err == nil {
+			return nil
+		}
+		var status *lokiStatusError
+		if attempt == s.config.MaxRetries || (errors.As(err, &status) && !status.retryable()) {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return err
+		}
+		backoff = min(backoff*2, s.config.MaxBackoff)
+	}
+}
+
+func (s *LokiSink) push(body []byte, compressed bool) error {
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.config.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.config.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.config.TenantID)
+	}
+
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &lokiStatusError{status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+// spool writes a request body to the spool directory, named so that file
+// names sort by age, and trims the spool to MaxSpoolSize.
+func (s *LokiSink) spool(body []byte) error {
+	name := fmt.Sprintf("%020d.json", time.Now().UnixNano())
+	if s.config.Compress {
+		name += ".gz"
+	}
+	path := filepath.Join(s.config.SpoolDir, name)
+
+	if err := os.WriteFile(path+".tmp", body, 0640); err != nil {
+		return fmt.Errorf("failed to spool log entries to '%s': %w", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("failed to spool log entries to '%s': %w", path, err)
+	}
+
+	files := s.spooled()
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+	for _, file := range files {
+		if total <= s.config.MaxSpoolSize {
+			break
+		}
+		if err := os.Remove(file.path); err == nil {
+			total -= file.size
+			reportError("Dropped spooled log entries %s because the spool is full", file.path)
+		}
+	}
+	return nil
+}
+
+type spoolFile struct {
+	path string
+	size int64
+}
+
+// spooled lists the spooled batches, oldest first.
+func (s *LokiSink) spooled() []spoolFile {
+	if s.config.SpoolDir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.config.SpoolDir)
+	if err != nil {
+		reportError("Failed to list loki spool %s: %v", s.config.SpoolDir, err)
+		return nil
+	}
+
+	var files []spoolFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, spoolFile{path: filepath.Join(s.config.SpoolDir, name), size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files
+}
+
+// drainSpool sends spooled batches, oldest first, until one fails. Each is
+// tried once; a failure marks the endpoint as down until the next probe.
+func (s *LokiSink) drainSpool() {
+	for _, file := range s.spooled() {
+		body, err := os.ReadFile(file.path)
+		if err != nil {
+			reportError("Failed to read spooled log entries %s: %v", file.path, err)
+			continue
+		}
+
+		err = s.push(body, strings.HasSuffix(file.path, ".gz"))
+		var status *lokiStatusError
+		if err != nil && !(errors.As(err, &status) && !status.retryable()) {
+			s.down = true
+			s.nextProbe = time.Now().Add(s.config.MaxBackoff)
+			return
+		}
+		if err != nil {
+			reportError("Loki rejected spooled log entries %s: %v", file.path, err)
+		}
+		os.Remove(file.path)
+	}
+	s.down = false
+}
+
+func lokiStreamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// lokiLabelName replaces the characters Loki does not allow in label names.
+func lokiLabelName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
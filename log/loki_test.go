@@ -0,0 +1,272 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lokiServer is a push endpoint answering with status, which tests may
+// change, and recording the requests it accepted.
+type lokiServer struct {
+	*httptest.Server
+	status   atomic.Int32
+	requests atomic.Int32
+	pushes   chan lokiPush
+	gzipped  atomic.Bool
+}
+
+func newLokiServer(t *testing.T) *lokiServer {
+	t.Helper()
+	s := &lokiServer{pushes: make(chan lokiPush, 64)}
+	s.status.Store(http.StatusNoContent)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		status := int(s.status.Load())
+		if status >= 300 {
+			http.Error(w, "unavailable", status)
+			return
+		}
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip body: %v", err)
+				return
+			}
+			body = gz
+			s.gzipped.Store(true)
+		}
+		var push lokiPush
+		if err := json.NewDecoder(body).Decode(&push); err != nil {
+			t.Errorf("decoding push: %v", err)
+		}
+		s.pushes <- push
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// lines returns the lines of the next accepted push, in order.
+func (s *lokiServer) lines(t *testing.T) []string {
+	t.Helper()
+	select {
+	case push := <-s.pushes:
+		var lines []string
+		for _, stream := range push.Streams {
+			for _, value := range stream.Values {
+				lines = append(lines, value[1])
+			}
+		}
+		return lines
+	case <-time.After(5 * time.Second):
+		t.Fatal("no push received")
+		return nil
+	}
+}
+
+func newTestLokiSink(t *testing.T, config LokiConfig) *LokiSink {
+	t.Helper()
+	s, err := NewLokiSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func writeLoki(s *LokiSink, lines ...string) {
+	for _, line := range lines {
+		s.Write(&Entry{Time: time.Now(), Level: ERROR, Message: line}, line)
+	}
+}
+
+func spoolNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestLokiCompressedPush(t *testing.T) {
+	server := newLokiServer(t)
+	s := newTestLokiSink(t, LokiConfig{
+		URL:         server.URL,
+		Compress:    true,
+		BatchWait:   time.Hour,
+		Labels:      map[string]string{"app": "api", "bad-name": "x"},
+		EntryLabels: func(entry *Entry) map[string]string { return map[string]string{"route": "/"} },
+	})
+
+	when := time.Unix(1700000000, 123)
+	s.Write(&Entry{Time: when, Level: WARN, Message: "compressed"}, "level=warn msg=compressed")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	push := <-server.pushes
+	if !server.gzipped.Load() {
+		t.Error("body was not gzipped")
+	}
+	if len(push.Streams) != 1 {
+		t.Fatalf("streams = %+v, want 1", push.Streams)
+	}
+	stream := push.Streams[0]
+	wantLabels := map[string]string{"app": "api", "bad_name": "x", "level": "warn", "route": "/"}
+	if len(stream.Stream) != len(wantLabels) {
+		t.Errorf("labels = %v, want %v", stream.Stream, wantLabels)
+	}
+	for name, value := range wantLabels {
+		if stream.Stream[name] != value {
+			t.Errorf("label %s = %q, want %q", name, stream.Stream[name], value)
+		}
+	}
+	want := [][2]string{{strconv.FormatInt(when.UnixNano(), 10), "level=warn msg=compressed"}}
+	if !slices.Equal(stream.Values, want) {
+		t.Errorf("values = %v, want %v", stream.Values, want)
+	}
+}
+
+func TestLokiClientErrorIsNotRetried(t *testing.T) {
+	server := newLokiServer(t)
+	server.status.Store(http.StatusBadRequest)
+	spool := t.TempDir()
+	s := newTestLokiSink(t, LokiConfig{
+		URL:        server.URL,
+		BatchWait:  time.Hour,
+		MaxRetries: 3,
+		MinBackoff: time.Millisecond,
+		SpoolDir:   spool,
+	})
+
+	writeLoki(s, "rejected")
+	if err := s.Flush(); err == nil {
+		t.Error("Flush succeeded although the push was rejected")
+	}
+	if n := server.requests.Load(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+	if names := spoolNames(t, spool); len(names) != 0 {
+		t.Errorf("rejected batch was spooled: %v", names)
+	}
+}
+
+func TestLokiServerErrorIsRetried(t *testing.T) {
+	server := newLokiServer(t)
+	server.status.Store(http.StatusServiceUnavailable)
+	s := newTestLokiSink(t, LokiConfig{
+		URL:        server.URL,
+		BatchWait:  time.Hour,
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+	})
+
+	writeLoki(s, "retried")
+	if err := s.Flush(); err == nil {
+		t.Error("Flush succeeded although every push failed")
+	}
+	if n := server.requests.Load(); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+}
+
+func TestLokiSpoolsWhileDown(t *testing.T) {
+	server := newLokiServer(t)
+	server.status.Store(http.StatusServiceUnavailable)
+	spool := t.TempDir()
+	s := newTestLokiSink(t, LokiConfig{
+		URL:        server.URL,
+		BatchWait:  10 * time.Millisecond,
+		MaxRetries: -1,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+		SpoolDir:   spool,
+	})
+
+	writeLoki(s, "first")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	writeLoki(s, "second")
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if names := spoolNames(t, spool); len(names) != 2 {
+		t.Fatalf("spool = %v, want 2 batches", names)
+	}
+
+	server.status.Store(http.StatusNoContent)
+	if got := server.lines(t); !slices.Equal(got, []string{"first"}) {
+		t.Errorf("first drained push = %q, want the oldest batch", got)
+	}
+	if got := server.lines(t); !slices.Equal(got, []string{"second"}) {
+		t.Errorf("second drained push = %q", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(spoolNames(t, spool)) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if names := spoolNames(t, spool); len(names) != 0 {
+		t.Errorf("spool = %v after draining", names)
+	}
+}
+
+func TestLokiMaxSpoolSize(t *testing.T) {
+	spool := t.TempDir()
+	s := &LokiSink{config: LokiConfig{SpoolDir: spool, MaxSpoolSize: 25}}
+
+	for _, body := range []string{"batch 0001", "batch 0002", "batch 0003"} {
+		if err := s.spool([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var kept []string
+	for _, file := range s.spooled() {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, string(data))
+	}
+	if want := []string{"batch 0002", "batch 0003"}; !slices.Equal(kept, want) {
+		t.Errorf("spool holds %q, want the newest %q", kept, want)
+	}
+	if names, _ := filepath.Glob(filepath.Join(spool, "*.tmp")); len(names) != 0 {
+		t.Errorf("temporary files left: %v", names)
+	}
+}
+
+func TestLokiCloseFlushes(t *testing.T) {
+	server := newLokiServer(t)
+	s, err := NewLokiSink(LokiConfig{URL: server.URL, BatchWait: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeLoki(s, "at close")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.lines(t); !slices.Equal(got, []string{"at close"}) {
+		t.Errorf("pushed %q on Close", got)
+	}
+}
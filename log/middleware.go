@@ -1,34 +1,407 @@
 package log
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net"
 	"net/http"
+	"net/netip"
+	"time"
 )
 
+// RequestIDHeader is the header LogRequest reads and echoes the request ID in.
+const RequestIDHeader = "X-Request-ID"
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int64
+	wroteHeader  bool
+	hijacked     bool
 }
 
 func NewLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
+// WriteHeader records the first final status code. Informational 1xx
+// responses other than 101 Switching Protocols may precede it.
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
+	if !lrw.wroteHeader && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		lrw.statusCode = code
+		lrw.wroteHeader = true
+	}
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
-func LogRequest() func(http.Handler) http.Handler {
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	lrw.wroteHeader = true
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+func (lrw *loggingResponseWriter) Flush() {
+	lrw.wroteHeader = true
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands over the connection. Unless a status was already written the
+// request is recorded as 101 Switching Protocols, as for websocket upgrades.
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		lrw.hijacked = true
+		if !lrw.wroteHeader {
+			lrw.statusCode = http.StatusSwitchingProtocols
+			lrw.wroteHeader = true
+		}
+	}
+	return conn, rw, err
+}
+
+func (lrw *loggingResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := lrw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (lrw *loggingResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	lrw.wroteHeader = true
+
+	var n int64
+	var err error
+	if readerFrom, ok := lrw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(src)
+	} else {
+		n, err = io.Copy(lrw.ResponseWriter, src)
+	}
+	lrw.bytesWritten += n
+	return n, err
+}
+
+// unwrappingResponseWriter is the part of loggingResponseWriter every
+// wrapper exposes; the optional interfaces are added only when the
+// underlying writer implements them.
+type unwrappingResponseWriter interface {
+	http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+// wrap returns lrw as a writer that implements exactly the optional
+// interfaces (http.Flusher, http.Hijacker, http.Pusher, io.ReaderFrom) of
+// the writer it records.
+func (lrw *loggingResponseWriter) wrap() http.ResponseWriter {
+	const (
+		flusher = 1 << iota
+		hijacker
+		pusher
+		readerFrom
+	)
+
+	var supported int
+	if _, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		supported |= flusher
+	}
+	if _, ok := lrw.ResponseWriter.(http.Hijacker); ok {
+		supported |= hijacker
+	}
+	if _, ok := lrw.ResponseWriter.(http.Pusher); ok {
+		supported |= pusher
+	}
+	if _, ok := lrw.ResponseWriter.(io.ReaderFrom); ok {
+		supported |= readerFrom
+	}
+
+	var w unwrappingResponseWriter = lrw
+	switch supported {
+	case flusher:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+		}{w, lrw}
+	case hijacker:
+		return struct {
+			unwrappingResponseWriter
+			http.Hijacker
+		}{w, lrw}
+	case pusher:
+		return struct {
+			unwrappingResponseWriter
+			http.Pusher
+		}{w, lrw}
+	case readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			io.ReaderFrom
+		}{w, lrw}
+	case flusher | hijacker:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, lrw, lrw}
+	case flusher | pusher:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			http.Pusher
+		}{w, lrw, lrw}
+	case flusher | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{w, lrw, lrw}
+	case hijacker | pusher:
+		return struct {
+			unwrappingResponseWriter
+			http.Hijacker
+			http.Pusher
+		}{w, lrw, lrw}
+	case hijacker | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{w, lrw, lrw}
+	case pusher | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Pusher
+			io.ReaderFrom
+		}{w, lrw, lrw}
+	case flusher | hijacker | pusher:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, lrw, lrw, lrw}
+	case flusher | hijacker | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, lrw, lrw, lrw}
+	case flusher | pusher | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{w, lrw, lrw, lrw}
+	case hijacker | pusher | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, lrw, lrw, lrw}
+	case flusher | hijacker | pusher | readerFrom:
+		return struct {
+			unwrappingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, lrw, lrw, lrw, lrw}
+	default:
+		return struct {
+			unwrappingResponseWriter
+		}{w}
+	}
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// RequestLogOption configures LogRequest.
+type RequestLogOption func(*requestLogOptions)
+
+type requestLogOptions struct {
+	format         AccessLogFormatter
+	fields         bool
+	trustedProxies []netip.Prefix
+
+	// Caller of LogRequest, reported for every access entry since they are
+	// logged from net/http's goroutines.
+	file string
+	line int
+	pkg  string
+}
+
+// WithAccessLogFormat sets how the access log message is rendered, e.g.
+// CommonLogFormat or CombinedLogFormat. Defaults to DefaultAccessLog.
+func WithAccessLogFormat(format AccessLogFormatter) RequestLogOption {
+	return func(o *requestLogOptions) {
+		o.format = format
+	}
+}
+
+// WithAccessLogFields controls whether the access record is also attached to
+// the entry as structured fields, for sinks that index them. Disabled by
+// default, since the message already carries the same values.
+func WithAccessLogFields(enabled bool) RequestLogOption {
+	return func(o *requestLogOptions) {
+		o.fields = enabled
+	}
+}
+
+// WithTrustedProxies sets the proxies whose Forwarded and X-Forwarded-For
+// headers are believed when determining the client address.
+func WithTrustedProxies(prefixes ...netip.Prefix) RequestLogOption {
+	return func(o *requestLogOptions) {
+		o.trustedProxies = append(o.trustedProxies, prefixes...)
+	}
+}
+
+// LogRequest logs one REQUEST entry per request. Each request gets an ID,
+// taken from the X-Request-ID header when the client sent a usable one, which
+// is echoed in the response and attached to the logger in the request's
+// context, so handlers logging through FromContext(r.Context()) or the
+// ...Context functions include it in their entries. The client address,
+// resolved through the trusted proxies, is available to handlers and
+// Recover through ClientIPFromContext. Entries are attributed to the caller
+// of LogRequest, whose package and file SetLevelSpec rules match.
+func LogRequest(opts ...RequestLogOption) func(http.Handler) http.Handler {
+	options := requestLogOptions{format: DefaultAccessLog}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.file, options.line, options.pkg = findCaller()
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+			ctx := WithRequestID(r.Context(), requestID)
+			ctx = context.WithValue(ctx, clientIPContextKey, clientIP(r, options.trustedProxies))
+			r = r.WithContext(ctx)
+			w.Header().Set(RequestIDHeader, requestID)
+
+			var body *countingReader
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &countingReader{ReadCloser: r.Body}
+				r.Body = body
+			}
+
 			loggingResponseWriter := NewLoggingResponseWriter(w)
-			next.ServeHTTP(loggingResponseWriter, r)
 
-			method := r.Method
-			path := r.URL.Path
-			status := loggingResponseWriter.statusCode
+			// Log from a deferred call so a panicking handler still gets an
+			// access entry; the panic continues once it has been written.
+			defer func() {
+				value := recover()
+				if value != nil && !loggingResponseWriter.wroteHeader {
+					loggingResponseWriter.statusCode = http.StatusInternalServerError
+				}
+
+				logAccess(ctx, r, loggingResponseWriter, body, start, requestID, &options)
+
+				if value != nil {
+					panic(value)
+				}
+			}()
 
-			Requestf("%v %s %s", status, method, path)
+			next.ServeHTTP(loggingResponseWriter.wrap(), r)
 		})
 	}
 }
+
+func logAccess(
+	ctx context.Context,
+	r *http.Request,
+	lrw *loggingResponseWriter,
+	body *countingReader,
+	start time.Time,
+	requestID string,
+	options *requestLogOptions,
+) {
+	record := &AccessRecord{
+		Time:       start,
+		Latency:    time.Since(start),
+		Status:     lrw.statusCode,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		RequestURI: r.RequestURI,
+		Proto:      r.Proto,
+		ClientIP:   ClientIPFromContext(ctx),
+		UserAgent:  r.UserAgent(),
+		Referer:    r.Referer(),
+		BytesOut:   lrw.bytesWritten,
+		Hijacked:   lrw.hijacked,
+		RequestID:  requestID,
+	}
+	if record.RequestURI == "" {
+		record.RequestURI = r.URL.RequestURI()
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		record.User = user
+	} else if r.URL.User != nil {
+		record.User = r.URL.User.Username()
+	}
+	if body != nil {
+		record.BytesIn = body.n
+	}
+
+	logger := FromContext(ctx)
+	if options.fields {
+		logger = logger.With(accessFields(record)...)
+	}
+	logAt(options.file, options.line, options.pkg, REQUEST, logger, nil, "%s", options.format(record))
+}
+
+// validRequestID accepts IDs of up to 128 characters from the unreserved URL
+// character set, which keeps client supplied values safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
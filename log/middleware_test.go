@@ -0,0 +1,344 @@
+package log
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeWriter is a response writer with none of the optional interfaces;
+// the fake* types below add them one at a time.
+type fakeWriter struct {
+	header   http.Header
+	deadline time.Time
+}
+
+func (w *fakeWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+func (w *fakeWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *fakeWriter) WriteHeader(int)             {}
+
+// SetWriteDeadline is only reachable through Unwrap.
+func (w *fakeWriter) SetWriteDeadline(deadline time.Time) error {
+	w.deadline = deadline
+	return nil
+}
+
+type fakeFlusher struct{}
+
+func (fakeFlusher) Flush() {}
+
+type fakeHijacker struct{}
+
+func (fakeHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return nil, nil, nil }
+
+type fakePusher struct{}
+
+func (fakePusher) Push(string, *http.PushOptions) error { return nil }
+
+type fakeReaderFrom struct{}
+
+func (fakeReaderFrom) ReadFrom(r io.Reader) (int64, error) { return io.Copy(io.Discard, r) }
+
+func TestWrapExposesUnderlyingInterfaces(t *testing.T) {
+	const (
+		f = 1 << iota // http.Flusher
+		h             // http.Hijacker
+		p             // http.Pusher
+		r             // io.ReaderFrom
+	)
+
+	type (
+		fl = fakeFlusher
+		hj = fakeHijacker
+		pu = fakePusher
+		rf = fakeReaderFrom
+	)
+	for _, tt := range []struct {
+		name   string
+		writer func(w *fakeWriter) http.ResponseWriter
+		want   int
+	}{
+		{"none", func(w *fakeWriter) http.ResponseWriter { return w }, 0},
+		{"F", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+			}{w, fl{}}
+		}, f},
+		{"H", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				hj
+			}{w, hj{}}
+		}, h},
+		{"P", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				pu
+			}{w, pu{}}
+		}, p},
+		{"R", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				rf
+			}{w, rf{}}
+		}, r},
+		{"FH", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				hj
+			}{w, fl{}, hj{}}
+		}, f | h},
+		{"FP", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				pu
+			}{w, fl{}, pu{}}
+		}, f | p},
+		{"FR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				rf
+			}{w, fl{}, rf{}}
+		}, f | r},
+		{"HP", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				hj
+				pu
+			}{w, hj{}, pu{}}
+		}, h | p},
+		{"HR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				hj
+				rf
+			}{w, hj{}, rf{}}
+		}, h | r},
+		{"PR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				pu
+				rf
+			}{w, pu{}, rf{}}
+		}, p | r},
+		{"FHP", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				hj
+				pu
+			}{w, fl{}, hj{}, pu{}}
+		}, f | h | p},
+		{"FHR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				hj
+				rf
+			}{w, fl{}, hj{}, rf{}}
+		}, f | h | r},
+		{"FPR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				pu
+				rf
+			}{w, fl{}, pu{}, rf{}}
+		}, f | p | r},
+		{"HPR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				hj
+				pu
+				rf
+			}{w, hj{}, pu{}, rf{}}
+		}, h | p | r},
+		{"FHPR", func(w *fakeWriter) http.ResponseWriter {
+			return struct {
+				*fakeWriter
+				fl
+				hj
+				pu
+				rf
+			}{w, fl{}, hj{}, pu{}, rf{}}
+		}, f | h | p | r},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &fakeWriter{}
+			wrapped := NewLoggingResponseWriter(tt.writer(base)).wrap()
+
+			_, isFlusher := wrapped.(http.Flusher)
+			_, isHijacker := wrapped.(http.Hijacker)
+			_, isPusher := wrapped.(http.Pusher)
+			_, isReaderFrom := wrapped.(io.ReaderFrom)
+			for _, check := range []struct {
+				name string
+				has  bool
+				bit  int
+			}{
+				{"http.Flusher", isFlusher, f},
+				{"http.Hijacker", isHijacker, h},
+				{"http.Pusher", isPusher, p},
+				{"io.ReaderFrom", isReaderFrom, r},
+			} {
+				if want := tt.want&check.bit != 0; check.has != want {
+					t.Errorf("implements %s = %v, want %v", check.name, check.has, want)
+				}
+			}
+
+			controller := http.NewResponseController(wrapped)
+			if err := controller.Flush(); (err == nil) != (tt.want&f != 0) {
+				t.Errorf("ResponseController.Flush() = %v", err)
+			}
+			if _, _, err := controller.Hijack(); (err == nil) != (tt.want&h != 0) {
+				t.Errorf("ResponseController.Hijack() = %v", err)
+			}
+			deadline := time.Now().Add(time.Minute)
+			if err := controller.SetWriteDeadline(deadline); err != nil {
+				t.Errorf("ResponseController.SetWriteDeadline() = %v", err)
+			}
+			if !base.deadline.Equal(deadline) {
+				t.Error("SetWriteDeadline did not reach the underlying writer through Unwrap")
+			}
+		})
+	}
+}
+
+func TestLoggingResponseWriterStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		serve func(w http.ResponseWriter)
+		want  int
+	}{
+		{"no WriteHeader", func(w http.ResponseWriter) { w.Write([]byte("ok")) }, http.StatusOK},
+		{"nothing written", func(w http.ResponseWriter) {}, http.StatusOK},
+		{"WriteHeader", func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) }, http.StatusNotFound},
+		{"informational first", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusCreated)
+		}, http.StatusCreated},
+		{"second WriteHeader ignored", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusAccepted)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusAccepted},
+		{"Hijack", func(w http.ResponseWriter) { w.(http.Hijacker).Hijack() }, http.StatusSwitchingProtocols},
+		{"Hijack after Write", func(w http.ResponseWriter) {
+			w.Write([]byte("ok"))
+			w.(http.Hijacker).Hijack()
+		}, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lrw := NewLoggingResponseWriter(struct {
+				*fakeWriter
+				fakeHijacker
+			}{&fakeWriter{}, fakeHijacker{}})
+			tt.serve(lrw.wrap())
+			if lrw.statusCode != tt.want {
+				t.Errorf("status = %d, want %d", lrw.statusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	for _, tt := range []struct {
+		id   string
+		want bool
+	}{
+		{"req-1", true},
+		{"0f8c_A.b~z", true},
+		{strings.Repeat("a", 128), true},
+		{"", false},
+		{strings.Repeat("a", 129), false},
+		{"has space", false},
+		{"new\nline", false},
+		{`quote"`, false},
+		{"semi;colon", false},
+		{"slash/", false},
+		{"café", false},
+	} {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		id := newRequestID()
+		if len(id) != 32 || strings.Trim(id, "0123456789abcdef") != "" {
+			t.Fatalf("newRequestID() = %q, want 32 hex digits", id)
+		}
+		if !validRequestID(id) {
+			t.Fatalf("generated ID %q is not accepted as a request ID", id)
+		}
+		if seen[id] {
+			t.Fatalf("newRequestID() repeated %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestLogRequestRequestID(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		header string
+		kept   bool
+	}{
+		{"valid", "client-id-1", true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", 129), false},
+		{"unsafe", "id\r\nX-Injected: 1", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := capture(t)
+			var seen string
+			handler := LogRequest()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+				FromContext(r.Context()).Info("in handler")
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.kept && id != tt.header {
+				t.Errorf("echoed ID = %q, want %q", id, tt.header)
+			}
+			if !tt.kept && (id == tt.header || len(id) != 32) {
+				t.Errorf("echoed ID = %q, want a generated one", id)
+			}
+			if seen != id {
+				t.Errorf("handler saw ID %q, response has %q", seen, id)
+			}
+
+			entries := sink.all()
+			if len(entries) != 2 {
+				t.Fatalf("logged %d entries, want the handler's and the access entry", len(entries))
+			}
+			for _, entry := range entries {
+				if got, _ := fieldValue(entry, RequestIDField); got != id {
+					t.Errorf("%q: request_id = %v, want %q", entry.Message, got, id)
+				}
+			}
+		})
+	}
+}
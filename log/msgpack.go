@@ -0,0 +1,287 @@
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// msgpackEncoder appends MessagePack values to buf. It covers what the
+// Fluent Forward protocol and log fields need, not the whole specification.
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) writeNil() {
+	e.buf = append(e.buf, 0xc0)
+}
+
+func (e *msgpackEncoder) writeBool(v bool) {
+	if v {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+func (e *msgpackEncoder) writeInt(v int64) {
+	switch {
+	case v >= 0:
+		e.writeUint(uint64(v))
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(v))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(v))
+	}
+}
+
+func (e *msgpackEncoder) writeUint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(v))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), v)
+	}
+}
+
+func (e *msgpackEncoder) writeFloat(v float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(v))
+}
+
+func (e *msgpackEncoder) writeString(v string) {
+	n := len(v)
+	switch {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, v...)
+}
+
+func (e *msgpackEncoder) writeBinary(v []byte) {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc5), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc6), uint32(n))
+	}
+	e.buf = append(e.buf, v...)
+}
+
+func (e *msgpackEncoder) writeArrayHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xdc), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdd), uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) writeMapHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xde), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdf), uint32(n))
+	}
+}
+
+// writeEventTime writes t as the Fluent EventTime extension (type 0): seconds
+// and nanoseconds as big-endian uint32s in a fixext 8.
+func (e *msgpackEncoder) writeEventTime(t time.Time) {
+	e.buf = append(e.buf, 0xd7, 0x00)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Unix()))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+}
+
+// writeValue writes a field value. Values without a MessagePack equivalent
+// are written as strings: times in RFC 3339, errors via Error() and
+// everything else via fmt.Sprint.
+func (e *msgpackEncoder) writeValue(value any) {
+	switch v := value.(type) {
+	case nil:
+		e.writeNil()
+	case string:
+		e.writeString(v)
+	case bool:
+		e.writeBool(v)
+	case []byte:
+		e.writeBinary(v)
+	case time.Time:
+		e.writeString(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		e.writeString(v.String())
+	case error:
+		e.writeString(v.Error())
+	case fmt.Stringer:
+		e.writeString(v.String())
+	default:
+		e.writeReflect(reflect.ValueOf(value))
+	}
+}
+
+func (e *msgpackEncoder) writeReflect(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.writeFloat(v.Float())
+	case reflect.Bool:
+		e.writeBool(v.Bool())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.writeNil()
+			return
+		}
+		e.writeArrayHeader(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e.writeValue(v.Index(i).Interface())
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.writeNil()
+			return
+		}
+		e.writeMapHeader(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e.writeString(fmt.Sprint(iter.Key().Interface()))
+			e.writeValue(iter.Value().Interface())
+		}
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.writeNil()
+			return
+		}
+		e.writeValue(v.Elem().Interface())
+	default:
+		e.writeString(fmt.Sprint(v.Interface()))
+	}
+}
+
+// readMsgpackStringMap reads a map whose keys and values are strings or
+// binaries, such as a Fluent Forward ack response.
+func readMsgpackStringMap(r *bufio.Reader) (map[string]string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+	switch {
+	case b&0xf0 == 0x80:
+		n = int(b & 0x0f)
+	case b == 0xde:
+		n, err = readMsgpackLength(r, 2)
+	case b == 0xdf:
+		n, err = readMsgpackLength(r, 4)
+	default:
+		return nil, fmt.Errorf("expected a msgpack map, got type 0x%02x", b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n > maxMsgpackMapSize {
+		return nil, fmt.Errorf("msgpack map of %d entries is too large", n)
+	}
+
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		key, err := readMsgpackString(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readMsgpackString(r)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// Bounds on what is read from a peer.
+const (
+	maxMsgpackString  = 1 << 20
+	maxMsgpackMapSize = 1024
+)
+
+func readMsgpackString(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	var n int
+	switch {
+	case b&0xe0 == 0xa0:
+		n = int(b & 0x1f)
+	case b == 0xd9 || b == 0xc4:
+		n, err = readMsgpackLength(r, 1)
+	case b == 0xda || b == 0xc5:
+		n, err = readMsgpackLength(r, 2)
+	case b == 0xdb || b == 0xc6:
+		n, err = readMsgpackLength(r, 4)
+	default:
+		return "", fmt.Errorf("expected a msgpack string, got type 0x%02x", b)
+	}
+	if err != nil {
+		return "", err
+	}
+	if n > maxMsgpackString {
+		return "", fmt.Errorf("msgpack string of %d bytes is too long", n)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func readMsgpackLength(r *bufio.Reader, size int) (int, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(data[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(data)), nil
+	default:
+		return int(binary.BigEndian.Uint32(data)), nil
+	}
+}
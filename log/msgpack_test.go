@@ -0,0 +1,300 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeMsgpack reads one value written by msgpackEncoder. Maps decode as
+// map[string]any, integers as int64 or uint64, binaries as []byte and the
+// Fluent EventTime extension as time.Time.
+func decodeMsgpack(r *bufio.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readN := func(n int) ([]byte, error) {
+		data := make([]byte, n)
+		_, err := io.ReadFull(r, data)
+		return data, err
+	}
+	readUint := func(size int) (uint64, error) {
+		data, err := readN(size)
+		if err != nil {
+			return 0, err
+		}
+		var v uint64
+		for _, b := range data {
+			v = v<<8 | uint64(b)
+		}
+		return v, nil
+	}
+	collection := func(n int, isMap bool) (any, error) {
+		if isMap {
+			m := make(map[string]any, n)
+			for i := 0; i < n; i++ {
+				key, err := decodeMsgpack(r)
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeMsgpack(r)
+				if err != nil {
+					return nil, err
+				}
+				m[fmt.Sprint(key)] = value
+			}
+			return m, nil
+		}
+		a := make([]any, n)
+		for i := range a {
+			if a[i], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+
+	switch {
+	case b <= 0x7f:
+		return uint64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return collection(int(b&0x0f), true)
+	case b&0xf0 == 0x90:
+		return collection(int(b&0x0f), false)
+	case b&0xe0 == 0xa0:
+		data, err := readN(int(b & 0x1f))
+		return string(data), err
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return b == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[b]
+		n, err := readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readN(int(n))
+		if b >= 0xd9 {
+			return string(data), err
+		}
+		return data, err
+	case 0xcb:
+		v, err := readUint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return readUint(1 << (b - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		v, err := readUint(size)
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, err
+	case 0xd7:
+		data, err := readN(9)
+		if err != nil || data[0] != 0x00 {
+			return nil, fmt.Errorf("unexpected fixext 8 %x: %v", data, err)
+		}
+		sec := binary.BigEndian.Uint32(data[1:5])
+		nsec := binary.BigEndian.Uint32(data[5:9])
+		return time.Unix(int64(sec), int64(nsec)), nil
+	case 0xdc, 0xde:
+		n, err := readUint(2)
+		if err != nil {
+			return nil, err
+		}
+		return collection(int(n), b == 0xde)
+	case 0xdd, 0xdf:
+		n, err := readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return collection(int(n), b == 0xdf)
+	}
+	return nil, fmt.Errorf("unsupported msgpack type 0x%02x", b)
+}
+
+func TestMsgpackInt(t *testing.T) {
+	for _, tt := range []struct {
+		value int64
+		want  []byte
+	}{
+		{0, []byte{0x00}},
+		{31, []byte{0x1f}},
+		{32, []byte{0x20}},
+		{127, []byte{0x7f}},
+		{128, []byte{0xcc, 0x80}},
+		{255, []byte{0xcc, 0xff}},
+		{256, []byte{0xcd, 0x01, 0x00}},
+		{65535, []byte{0xcd, 0xff, 0xff}},
+		{65536, []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{math.MaxUint32, []byte{0xce, 0xff, 0xff, 0xff, 0xff}},
+		{math.MaxUint32 + 1, []byte{0xcf, 0, 0, 0, 0x01, 0, 0, 0, 0}},
+		{-1, []byte{0xff}},
+		{-32, []byte{0xe0}},
+		{-33, []byte{0xd0, 0xdf}},
+		{math.MinInt8, []byte{0xd0, 0x80}},
+		{math.MinInt8 - 1, []byte{0xd1, 0xff, 0x7f}},
+		{math.MinInt16, []byte{0xd1, 0x80, 0x00}},
+		{math.MinInt16 - 1, []byte{0xd2, 0xff, 0xff, 0x7f, 0xff}},
+		{math.MinInt32, []byte{0xd2, 0x80, 0, 0, 0}},
+		{math.MinInt32 - 1, []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff}},
+	} {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			var e msgpackEncoder
+			e.writeInt(tt.value)
+			if !bytes.Equal(e.buf, tt.want) {
+				t.Errorf("writeInt(%d) = % x, want % x", tt.value, e.buf, tt.want)
+			}
+
+			got, err := decodeMsgpack(bufio.NewReader(bytes.NewReader(e.buf)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.value) {
+				t.Errorf("decoded %v, want %d", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestMsgpackUint(t *testing.T) {
+	for _, tt := range []struct {
+		value uint64
+		want  []byte
+	}{
+		{127, []byte{0x7f}},
+		{128, []byte{0xcc, 0x80}},
+		{255, []byte{0xcc, 0xff}},
+		{256, []byte{0xcd, 0x01, 0x00}},
+		{65535, []byte{0xcd, 0xff, 0xff}},
+		{65536, []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{math.MaxUint32, []byte{0xce, 0xff, 0xff, 0xff, 0xff}},
+		{math.MaxUint64, []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	} {
+		var e msgpackEncoder
+		e.writeUint(tt.value)
+		if !bytes.Equal(e.buf, tt.want) {
+			t.Errorf("writeUint(%d) = % x, want % x", tt.value, e.buf, tt.want)
+		}
+	}
+}
+
+// TestMsgpackLengthHeaders checks the header chosen for each length on both
+// sides of every size boundary.
+func TestMsgpackLengthHeaders(t *testing.T) {
+	str := func(n int) func(*msgpackEncoder) {
+		return func(e *msgpackEncoder) { e.writeString(strings.Repeat("x", n)) }
+	}
+	bin := func(n int) func(*msgpackEncoder) {
+		return func(e *msgpackEncoder) { e.writeBinary(make([]byte, n)) }
+	}
+	array := func(n int) func(*msgpackEncoder) {
+		return func(e *msgpackEncoder) { e.writeArrayHeader(n) }
+	}
+	dict := func(n int) func(*msgpackEncoder) {
+		return func(e *msgpackEncoder) { e.writeMapHeader(n) }
+	}
+
+	for _, tt := range []struct {
+		name   string
+		write  func(*msgpackEncoder)
+		header []byte
+		body   int
+	}{
+		{"string 0", str(0), []byte{0xa0}, 0},
+		{"string 31", str(31), []byte{0xbf}, 31},
+		{"string 32", str(32), []byte{0xd9, 0x20}, 32},
+		{"string 255", str(255), []byte{0xd9, 0xff}, 255},
+		{"string 256", str(256), []byte{0xda, 0x01, 0x00}, 256},
+		{"string 65535", str(65535), []byte{0xda, 0xff, 0xff}, 65535},
+		{"string 65536", str(65536), []byte{0xdb, 0x00, 0x01, 0x00, 0x00}, 65536},
+		{"binary 0", bin(0), []byte{0xc4, 0x00}, 0},
+		{"binary 255", bin(255), []byte{0xc4, 0xff}, 255},
+		{"binary 256", bin(256), []byte{0xc5, 0x01, 0x00}, 256},
+		{"binary 65535", bin(65535), []byte{0xc5, 0xff, 0xff}, 65535},
+		{"binary 65536", bin(65536), []byte{0xc6, 0x00, 0x01, 0x00, 0x00}, 65536},
+		{"array 15", array(15), []byte{0x9f}, 0},
+		{"array 16", array(16), []byte{0xdc, 0x00, 0x10}, 0},
+		{"array 65535", array(65535), []byte{0xdc, 0xff, 0xff}, 0},
+		{"array 65536", array(65536), []byte{0xdd, 0x00, 0x01, 0x00, 0x00}, 0},
+		{"map 15", dict(15), []byte{0x8f}, 0},
+		{"map 16", dict(16), []byte{0xde, 0x00, 0x10}, 0},
+		{"map 65535", dict(65535), []byte{0xde, 0xff, 0xff}, 0},
+		{"map 65536", dict(65536), []byte{0xdf, 0x00, 0x01, 0x00, 0x00}, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var e msgpackEncoder
+			tt.write(&e)
+			if !bytes.HasPrefix(e.buf, tt.header) {
+				t.Errorf("header = % x, want % x", e.buf[:min(len(e.buf), len(tt.header))], tt.header)
+			}
+			if want := len(tt.header) + tt.body; len(e.buf) != want {
+				t.Errorf("encoded %d bytes, want %d", len(e.buf), want)
+			}
+		})
+	}
+}
+
+func TestMsgpackEventTime(t *testing.T) {
+	var e msgpackEncoder
+	e.writeEventTime(time.Unix(1700000000, 123456789))
+
+	want := []byte{0xd7, 0x00, 0x65, 0x53, 0xf1, 0x00, 0x07, 0x5b, 0xcd, 0x15}
+	if !bytes.Equal(e.buf, want) {
+		t.Errorf("writeEventTime() = % x, want % x", e.buf, want)
+	}
+}
+
+func TestReadMsgpackStringMap(t *testing.T) {
+	var ack msgpackEncoder
+	ack.writeMapHeader(1)
+	ack.writeString("ack")
+	ack.writeBinary([]byte("chunk-id"))
+
+	var long msgpackEncoder
+	long.writeMapHeader(1)
+	long.writeString("ack")
+	long.buf = binary.BigEndian.AppendUint32(append(long.buf, 0xdb), maxMsgpackString+1)
+
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		want    map[string]string
+		wantErr string
+	}{
+		{"ack", ack.buf, map[string]string{"ack": "chunk-id"}, ""},
+		{"not a map", []byte{0x91, 0xa0}, nil, "expected a msgpack map"},
+		{"too many entries", binary.BigEndian.AppendUint32([]byte{0xdf}, maxMsgpackMapSize+1), nil, "too large"},
+		{"string too long", long.buf, nil, "too long"},
+		{"non-string value", []byte{0x81, 0xa3, 'a', 'c', 'k', 0x01}, nil, "expected a msgpack string"},
+		{"truncated", ack.buf[:len(ack.buf)-1], nil, "EOF"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readMsgpackStringMap(bufio.NewReader(bytes.NewReader(tt.data)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) || got["ack"] != tt.want["ack"] {
+				t.Errorf("readMsgpackStringMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fields added to entries logged with a context carrying an OpenTelemetry span.
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+)
+
+var spanEvents atomic.Bool
+
+// SetSpanEvents controls whether ERROR and PANIC entries logged with a
+// context carrying a recording span are also added to the span as "log"
+// events, with the level, message, caller and fields as attributes.
+func SetSpanEvents(enabled bool) {
+	spanEvents.Store(enabled)
+}
+
+// WithContext returns a child logger that adds the trace and span IDs of the
+// OpenTelemetry span in ctx to its entries, replacing any span set before.
+// If ctx carries no valid span, l is returned.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return l
+	}
+	return &Logger{name: l.name, fields: l.fields, span: span}
+}
+
+// traceFields returns fields plus the trace and span IDs of span, if it is
+// valid. fields itself is never modified.
+func traceFields(span trace.Span, fields []Field) []Field {
+	if span == nil {
+		return fields
+	}
+	spanContext := span.SpanContext()
+	if !spanContext.IsValid() {
+		return fields
+	}
+
+	traced := make([]Field, len(fields), len(fields)+2)
+	copy(traced, fields)
+	return append(traced,
+		Field{Key: TraceIDField, Value: spanContext.TraceID().String()},
+		Field{Key: SpanIDField, Value: spanContext.SpanID().String()},
+	)
+}
+
+// addSpanEvent records an ERROR or PANIC entry on span if span events are enabled.
+func addSpanEvent(span trace.Span, entry *Entry) {
+	if span == nil || entry.Level > ERROR || !spanEvents.Load() || !span.IsRecording() {
+		return
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(entry.Fields)+4)
+	attrs = append(attrs,
+		attribute.String("log.severity", entry.Level.String()),
+		attribute.String("log.message", entry.Message),
+		attribute.String("code.filepath", entry.File),
+		attribute.Int("code.lineno", entry.Line),
+	)
+	for _, field := range entry.Fields {
+		switch field.Key {
+		case TraceIDField, SpanIDField:
+			continue
+		}
+		attrs = append(attrs, attribute.String(field.Key, fmt.Sprint(field.Value)))
+	}
+
+	span.AddEvent("log", trace.WithTimestamp(entry.Time), trace.WithAttributes(attrs...))
+}
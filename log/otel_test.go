@@ -0,0 +1,167 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingSpan is a span that reports itself as recording and keeps the
+// events added to it. Everything else is left to the non-recording span it
+// wraps.
+type recordingSpan struct {
+	trace.Span
+
+	mu     sync.Mutex
+	events []trace.EventConfig
+	names  []string
+}
+
+func newRecordingSpan(ctx context.Context) (*recordingSpan, context.Context) {
+	span := &recordingSpan{Span: trace.SpanFromContext(spanContext(ctx, 2))}
+	return span, trace.ContextWithSpan(ctx, span)
+}
+
+func (s *recordingSpan) IsRecording() bool { return true }
+
+func (s *recordingSpan) AddEvent(name string, options ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names = append(s.names, name)
+	s.events = append(s.events, trace.NewEventConfig(options...))
+}
+
+func (s *recordingSpan) recorded() ([]string, []trace.EventConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.names), slices.Clone(s.events)
+}
+
+// spanContext returns ctx carrying a remote span whose IDs end in n.
+func spanContext(ctx context.Context, n byte) context.Context {
+	return trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{15: n},
+		SpanID:     trace.SpanID{7: n},
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+// traceIDs returns the trace and span ID fields of entry.
+func traceIDs(t *testing.T, entry *Entry) (traceID, spanID any) {
+	t.Helper()
+	var n int
+	for _, field := range entry.Fields {
+		switch field.Key {
+		case TraceIDField:
+			traceID = field.Value
+			n++
+		case SpanIDField:
+			spanID = field.Value
+			n++
+		}
+	}
+	if n > 2 {
+		t.Errorf("fields %v hold the trace IDs more than once", entry.Fields)
+	}
+	return traceID, spanID
+}
+
+func TestTraceIDsFromContext(t *testing.T) {
+	sink := capture(t)
+	ctx := spanContext(context.Background(), 1)
+	const (
+		traceID = "00000000000000000000000000000001"
+		spanID  = "0000000000000001"
+	)
+
+	InfoContext(ctx, "from context")
+	New("user", "ada").WithContext(ctx).Info("from logger")
+	New().WithContext(spanContext(ctx, 2)).WithContext(ctx).Info("span replaced")
+	slog.New(NewSlogHandler(Default())).InfoContext(ctx, "from slog")
+
+	entries := sink.all()
+	if len(entries) != 4 {
+		t.Fatalf("logged %d entries, want 4", len(entries))
+	}
+	for _, entry := range entries {
+		if gotTrace, gotSpan := traceIDs(t, entry); gotTrace != traceID || gotSpan != spanID {
+			t.Errorf("%q: trace_id = %v, span_id = %v", entry.Message, gotTrace, gotSpan)
+		}
+	}
+	if user, _ := fieldValue(entries[1], "user"); user != "ada" {
+		t.Errorf("user = %v, want the logger's fields kept", user)
+	}
+}
+
+func TestWithContextWithoutSpan(t *testing.T) {
+	logger := New("user", "ada")
+	for name, ctx := range map[string]context.Context{
+		"nil":     nil,
+		"no span": context.Background(),
+		"invalid": trace.ContextWithSpanContext(context.Background(), trace.SpanContext{}),
+	} {
+		if got := logger.WithContext(ctx); got != logger {
+			t.Errorf("%s: WithContext returned a new logger", name)
+		}
+	}
+
+	// traceFields must not write into the logger's fields.
+	fields := make([]Field, 1, 4)
+	fields[0] = Field{Key: "user", Value: "ada"}
+	traced := traceFields(trace.SpanFromContext(spanContext(context.Background(), 1)), fields)
+	if len(traced) != 3 || fields[:2][1] != (Field{}) {
+		t.Errorf("traceFields() = %v, fields = %v", traced, fields[:2])
+	}
+}
+
+func TestSpanEvents(t *testing.T) {
+	sink := capture(t)
+	span, ctx := newRecordingSpan(context.Background())
+	logger := FromContext(ctx).With("user", "ada")
+
+	logger.Error("events disabled")
+	if names, _ := span.recorded(); len(names) != 0 {
+		t.Fatalf("events %q added while span events were disabled", names)
+	}
+
+	SetSpanEvents(true)
+	t.Cleanup(func() { SetSpanEvents(false) })
+
+	logger.Warn("warn is not an event")
+	ErrorContext(spanContext(ctx, 3), "not recording")
+	logger.WithError(errors.New("boom")).Error("failed")
+
+	names, events := span.recorded()
+	if !slices.Equal(names, []string{"log"}) {
+		t.Fatalf("events = %q, want one log event", names)
+	}
+	var entry *Entry
+	for _, e := range sink.all() {
+		if e.Message == "failed" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatal("the error was not logged")
+	}
+	if got := events[0].Timestamp(); !got.Equal(entry.Time) {
+		t.Errorf("event time = %v, want the entry's %v", got, entry.Time)
+	}
+	want := []attribute.KeyValue{
+		attribute.String("log.severity", "ERROR"),
+		attribute.String("log.message", "failed"),
+		attribute.String("code.filepath", entry.File),
+		attribute.Int("code.lineno", entry.Line),
+		attribute.String("user", "ada"),
+		attribute.String(ErrorField, "boom"),
+	}
+	if got := events[0].Attributes(); !slices.Equal(got, want) {
+		t.Errorf("attributes = %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package log
+
+import (
+	"sync"
+)
+
+// DefaultRecentSize is the number of entries kept in memory by default.
+const DefaultRecentSize = 1000
+
+var (
+	recentMu      sync.Mutex
+	recentEntries = make([]*Entry, DefaultRecentSize)
+	recentHead    int
+	recentCount   int
+)
+
+// recordRecent adds an entry to the in-memory ring, overwriting the oldest
+// one once it is full. Callers must hold recentMu.
+func recordRecent(entry *Entry) {
+	if len(recentEntries) == 0 {
+		return
+	}
+
+	recentEntries[(recentHead+recentCount)%len(recentEntries)] = entry
+	if recentCount < len(recentEntries) {
+		recentCount++
+	} else {
+		recentHead = (recentHead + 1) % len(recentEntries)
+	}
+}
+
+// SetRecentSize changes how many entries are kept for Recent and for the
+// replay into the first log file, keeping the newest ones. 0 disables it.
+func SetRecentSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+
+	recentMu.Lock()
+	defer recentMu.Unlock()
+
+	kept := recentSnapshot()
+	if len(kept) > size {
+		kept = kept[len(kept)-size:]
+	}
+
+	recentEntries = make([]*Entry, size)
+	copy(recentEntries, kept)
+	recentHead = 0
+	recentCount = len(kept)
+}
+
+// Recent returns up to n of the latest entries at minLevel or more severe,
+// oldest first. REQUEST entries count as INFO. n <= 0 returns all of them.
+// The returned entries are shared and must not be modified.
+func Recent(n int, minLevel LogLevel) []*Entry {
+	recentMu.Lock()
+	all := recentSnapshot()
+	recentMu.Unlock()
+
+	if minLevel == REQUEST {
+		minLevel = INFO
+	}
+
+	var matched []*Entry
+	for i := len(all) - 1; i >= 0; i-- {
+		level := all[i].Level
+		if level == REQUEST {
+			level = INFO
+		}
+		if level > minLevel {
+			continue
+		}
+
+		matched = append(matched, all[i])
+		if n > 0 && len(matched) == n {
+			break
+		}
+	}
+
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return matched
+}
+
+// recentSnapshot returns the ring's entries, oldest first. Callers must hold recentMu.
+func recentSnapshot() []*Entry {
+	entries := make([]*Entry, recentCount)
+	for i := range entries {
+		entries[i] = recentEntries[(recentHead+i)%len(recentEntries)]
+	}
+	return entries
+}
@@ -0,0 +1,212 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// reconnectingWriter sends the messages of a network sink from a background
+// goroutine, so a slow or unreachable peer never blocks logging. Messages
+// are buffered while the connection is down, dropping the oldest once
+// bufferSize is reached, and the connection is redialled at most once per
+// reconnectDelay. SyslogSink and FluentSink are built on it.
+type reconnectingWriter struct {
+	// name and unit describe the peer and what is sent to it in errors,
+	// e.g. "syslog" and "messages".
+	name string
+	unit string
+
+	bufferSize     int
+	batchSize      int           // messages handed to send at once
+	interval       time.Duration // how often buffered messages are sent
+	reconnectDelay time.Duration
+
+	// dial opens a connection. It is called without holding mu, so Write
+	// does not wait for it.
+	dial func() (net.Conn, error)
+	// send writes a batch of messages to conn. On error the whole batch is
+	// kept and the connection closed.
+	send func(conn net.Conn, batch [][]byte) error
+
+	mu      sync.Mutex
+	buffer  [][]byte
+	dropped int
+	failing bool
+	wake    chan struct{}
+
+	// Only used by the sending goroutine, and by close once it has stopped.
+	conn     net.Conn
+	lastDial time.Time
+
+	flushReq chan chan error
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// start dials the peer and starts the sending goroutine, which runs even if
+// dialing failed. The fields above mu must be set.
+func (w *reconnectingWriter) start() error {
+	w.wake = make(chan struct{}, 1)
+	w.flushReq = make(chan chan error)
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	err := w.connect()
+	if err != nil {
+		w.failing = true
+	}
+	go w.run()
+	return err
+}
+
+func (w *reconnectingWriter) connect() error {
+	w.lastDial = time.Now()
+	conn, err := w.dial()
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// write buffers msg, waking the sending goroutine once a batch is full.
+func (w *reconnectingWriter) write(msg []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buffer = append(w.buffer, msg)
+	w.trim()
+	if len(w.buffer) >= w.batchSize {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// trim drops the oldest messages beyond bufferSize. Callers must hold mu.
+func (w *reconnectingWriter) trim() {
+	if over := len(w.buffer) - w.bufferSize; over > 0 {
+		w.buffer = w.buffer[over:]
+		w.dropped += over
+	}
+}
+
+func (w *reconnectingWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.wake:
+		case <-ticker.C:
+		case result := <-w.flushReq:
+			result <- w.drain(true)
+			continue
+		case <-w.stop:
+			return
+		}
+		if err := w.drain(false); err != nil {
+			reportError("%v", err)
+		}
+	}
+}
+
+// drain sends the buffered messages in batches, reconnecting first if
+// needed. Unless force is set, reconnects are limited to one per
+// reconnectDelay. Only the first failure after a working connection is
+// returned, so a down peer does not produce one error per batch. Only the
+// sending goroutine calls it, so batches are sent in order.
+func (w *reconnectingWriter) drain(force bool) error {
+	for {
+		w.mu.Lock()
+		empty := len(w.buffer) == 0
+		w.mu.Unlock()
+		if empty {
+			return nil
+		}
+
+		if w.conn == nil {
+			if !force && time.Since(w.lastDial) < w.reconnectDelay {
+				return nil
+			}
+			if err := w.connect(); err != nil {
+				return w.fail(err)
+			}
+		}
+
+		w.mu.Lock()
+		if w.dropped > 0 {
+			reportError("Dropped %d %s %s while disconnected", w.dropped, w.name, w.unit)
+			w.dropped = 0
+		}
+		n := min(len(w.buffer), w.batchSize)
+		batch := w.buffer[:n:n]
+		w.buffer = w.buffer[n:]
+		w.mu.Unlock()
+
+		if err := w.send(w.conn, batch); err != nil {
+			w.conn.Close()
+			w.conn = nil
+
+			// Put the batch back in front of what arrived meanwhile.
+			w.mu.Lock()
+			w.buffer = append(batch, w.buffer...)
+			w.trim()
+			w.mu.Unlock()
+			return w.fail(err)
+		}
+
+		w.mu.Lock()
+		w.failing = false
+		w.mu.Unlock()
+	}
+}
+
+func (w *reconnectingWriter) fail(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failing {
+		return nil
+	}
+	w.failing = true
+	return fmt.Errorf("%s unavailable, buffering %s: %w", w.name, w.unit, err)
+}
+
+// flush sends any buffered messages, reconnecting immediately if needed.
+func (w *reconnectingWriter) flush() error {
+	w.mu.Lock()
+	w.failing = false
+	w.mu.Unlock()
+
+	result := make(chan error, 1)
+	select {
+	case w.flushReq <- result:
+		return <-result
+	case <-w.done:
+		return nil
+	}
+}
+
+// close sends any buffered messages, stops the sending goroutine and closes
+// the connection.
+func (w *reconnectingWriter) close() error {
+	err := w.flush()
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+
+	if w.conn == nil {
+		return err
+	}
+	if closeErr := w.conn.Close(); err == nil {
+		err = closeErr
+	}
+	w.conn = nil
+	return err
+}
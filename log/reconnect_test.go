@@ -0,0 +1,149 @@
+package log
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder is a reconnectingWriter send function recording the batches
+// it is given. fail, if set, is called first and may fail the batch.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	fail    func(call int) error
+	calls   int
+}
+
+func (r *batchRecorder) send(conn net.Conn, batch [][]byte) error {
+	r.mu.Lock()
+	r.calls++
+	call := r.calls
+	r.mu.Unlock()
+	if r.fail != nil {
+		if err := r.fail(call); err != nil {
+			return err
+		}
+	}
+
+	var messages []string
+	for _, msg := range batch {
+		messages = append(messages, string(msg))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, messages)
+	return nil
+}
+
+func (r *batchRecorder) sent() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.batches)
+}
+
+func pipeConn() (net.Conn, error) {
+	conn, peer := net.Pipe()
+	peer.Close()
+	return conn, nil
+}
+
+func TestReconnectingWriterDoesNotBlockWritesWhileDialing(t *testing.T) {
+	dialing := make(chan struct{})
+	gate := make(chan struct{})
+	dials := 0
+	recorder := &batchRecorder{}
+	w := &reconnectingWriter{
+		name: "test", unit: "messages",
+		bufferSize: 10, batchSize: 10,
+		interval: time.Hour, reconnectDelay: time.Hour,
+		dial: func() (net.Conn, error) {
+			dials++
+			if dials == 1 {
+				return nil, errors.New("down")
+			}
+			close(dialing)
+			<-gate
+			return pipeConn()
+		},
+		send: recorder.send,
+	}
+	if err := w.start(); err == nil {
+		t.Fatal("start() succeeded although dialing failed")
+	}
+	defer w.close()
+
+	w.write([]byte("a"))
+	flushed := make(chan error, 1)
+	go func() { flushed <- w.flush() }()
+	<-dialing
+
+	written := make(chan struct{})
+	go func() {
+		w.write([]byte("b"))
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("write blocked while the peer was being dialled")
+	}
+
+	close(gate)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	if got := recorder.sent(); len(got) != 1 || !slices.Equal(got[0], []string{"a", "b"}) {
+		t.Errorf("sent %q, want one batch of a and b", got)
+	}
+}
+
+func TestReconnectingWriterRequeuesFailedBatch(t *testing.T) {
+	var w *reconnectingWriter
+	recorder := &batchRecorder{fail: func(call int) error {
+		if call > 1 {
+			return nil
+		}
+		// A message written while the failing batch is in flight.
+		w.write([]byte("d"))
+		return errors.New("connection reset")
+	}}
+	w = &reconnectingWriter{
+		name: "test", unit: "messages",
+		bufferSize: 3, batchSize: 2,
+		interval: time.Hour, reconnectDelay: time.Hour,
+		dial: pipeConn,
+		send: recorder.send,
+	}
+	if err := w.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	stderr := captureStderr(t)
+
+	w.mu.Lock()
+	w.buffer = [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	w.mu.Unlock()
+
+	err := w.flush()
+	if err == nil || !strings.Contains(err.Error(), "test unavailable, buffering messages: connection reset") {
+		t.Fatalf("flush() = %v, want the send error", err)
+	}
+
+	// The failed batch went back in front of c and d, and the oldest entry
+	// was dropped to stay within bufferSize.
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"b", "c"}, {"d"}}
+	if got := recorder.sent(); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if output := stderr(); !strings.Contains(output, "Dropped 1 test messages while disconnected") {
+		t.Errorf("stderr = %q, want the dropped message reported", output)
+	}
+}
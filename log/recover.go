@@ -0,0 +1,131 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// RecoverOption configures Recover.
+type RecoverOption func(*recoverOptions)
+
+type recoverOptions struct {
+	response     http.Handler
+	repanicAbort bool
+}
+
+// WithRecoverResponse sets the handler that writes the response after a
+// panic, if the handler had not written headers yet. Defaults to a plain 500.
+func WithRecoverResponse(response http.Handler) RecoverOption {
+	return func(o *recoverOptions) {
+		o.response = response
+	}
+}
+
+// WithAbortRepanic controls whether a panic with http.ErrAbortHandler is
+// passed on to net/http, which aborts the response without logging. Enabled
+// by default; when disabled the abort is logged at WARN and swallowed.
+func WithAbortRepanic(enabled bool) RecoverOption {
+	return func(o *recoverOptions) {
+		o.repanicAbort = enabled
+	}
+}
+
+// Recover catches panics in the wrapped handler and logs them at ERROR with
+// the goroutine's stack and the request's details, attributed to the
+// function that panicked. Place it inside LogRequest so the access log
+// records the resulting status:
+//
+//	handler = log.LogRequest()(log.Recover()(mux))
+func Recover(opts ...RecoverOption) func(http.Handler) http.Handler {
+	options := recoverOptions{
+		response: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}),
+		repanicAbort: true,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	recoverFile, recoverLine, recoverPkg := findCaller()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lrw := NewLoggingResponseWriter(w)
+
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+
+				// findCaller would stop in the runtime, so the entry is
+				// attributed to the code that panicked, or else to the caller
+				// of Recover.
+				file, line, pkg, ok := panicCaller()
+				if !ok {
+					file, line, pkg = recoverFile, recoverLine, recoverPkg
+				}
+
+				// LogRequest has already resolved the address through any
+				// trusted proxies; without it only the peer is known.
+				ip := ClientIPFromContext(r.Context())
+				if ip == "" {
+					ip = clientIP(r, nil)
+				}
+				logger := FromContext(r.Context()).With(
+					"method", r.Method,
+					"path", r.URL.Path,
+					"client_ip", ip,
+				)
+
+				if err, ok := value.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					if options.repanicAbort {
+						panic(value)
+					}
+					logAt(file, line, pkg, WARN, logger, nil, "Handler aborted %s %s", r.Method, r.URL.Path)
+					return
+				}
+
+				logger = logger.With("panic", fmt.Sprint(value), "stack", string(debug.Stack()))
+				logAt(file, line, pkg, ERROR, logger, nil, "Panic serving %s %s: %v", r.Method, r.URL.Path, value)
+
+				if !lrw.wroteHeader {
+					options.response.ServeHTTP(w, r)
+				}
+			}()
+
+			next.ServeHTTP(lrw.wrap(), r)
+		})
+	}
+}
+
+// panicCaller returns the file name, line and package import path of the
+// function that panicked: the first frame below runtime.gopanic outside the
+// runtime, this package and the standard log package it panics through. It
+// must be called from the deferred function that recovered.
+func panicCaller() (string, int, string, bool) {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking &&
+			!strings.HasPrefix(frame.Function, "runtime.") &&
+			!strings.HasPrefix(frame.Function, "log.") &&
+			!strings.HasPrefix(frame.Function, loggerPackagePath+"."):
+			return filepath.Base(frame.File), frame.Line, packageOf(frame.Function), true
+		}
+		if !more {
+			return "", 0, "", false
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRecoverClientIP(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	trusted := WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"))
+
+	for _, tt := range []struct {
+		name    string
+		handler http.Handler
+		want    string
+	}{
+		{"inside LogRequest", LogRequest(trusted)(Recover()(panicking)), "198.51.100.1"},
+		{"without LogRequest", Recover()(panicking), "10.0.0.1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := capture(t)
+			r := httptest.NewRequest(http.MethodGet, "/panic", nil)
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header.Set("X-Forwarded-For", "198.51.100.1")
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", w.Code)
+			}
+			var found bool
+			for _, entry := range sink.all() {
+				if entry.Level != ERROR {
+					continue
+				}
+				found = true
+				if ip, _ := fieldValue(entry, "client_ip"); ip != tt.want {
+					t.Errorf("client_ip = %v, want %s", ip, tt.want)
+				}
+			}
+			if !found {
+				t.Fatalf("no ERROR entry logged: %q", sink.messages())
+			}
+		})
+	}
+}
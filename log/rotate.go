@@ -0,0 +1,344 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Rotation controls when the log file is rotated and which rotated files are kept.
+// The zero value never rotates.
+type Rotation struct {
+	// MaxSize rotates the file before a write would take it past this many bytes. 0 disables.
+	MaxSize int64
+	// Daily rotates the file on the first write after local midnight.
+	Daily bool
+	// MaxBackups is the number of rotated files to keep. 0 keeps all of them.
+	MaxBackups int
+	// MaxAge removes rotated files opened longer ago than this. 0 keeps them
+	// regardless of age.
+	MaxAge time.Duration
+	// Compress gzips rotated files in the background.
+	Compress bool
+	// Symlink writes to timestamped files and keeps the configured path as a
+	// symlink to the current one, instead of renaming the file on rotation.
+	Symlink bool
+}
+
+// WithRotation enables rotation and retention of the log file.
+func WithRotation(rotation Rotation) FileOption {
+	return func(o *fileOptions) {
+		o.rotation = rotation
+	}
+}
+
+// rotatingFile is the writer behind FileSink. Write, rotate and Close must be
+// called with the sink's mutex held; cleanup of old files runs on its own
+// goroutine under millMu, and Close waits for it.
+type rotatingFile struct {
+	path     string
+	rotation Rotation
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	current  atomic.Value // base name of the open file, read by mill
+
+	millMu  sync.Mutex
+	milling sync.WaitGroup
+}
+
+func openRotatingFile(path string, rotation Rotation) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, rotation: rotation}
+	if err := f.open(time.Now()); err != nil {
+		return nil, err
+	}
+
+	f.startMill()
+	return f, nil
+}
+
+// open opens the current file, appending to it if it already exists.
+func (f *rotatingFile) open(now time.Time) error {
+	name := f.path
+	if f.rotation.Symlink {
+		if target, err := os.Readlink(f.path); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(f.path), target)
+			}
+			name = target
+		} else {
+			name = f.backupName(now)
+		}
+	}
+
+	return f.openNamed(name, now)
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	now := time.Now()
+	if f.shouldRotate(now, len(p)) {
+		if err := f.rotate(now); err != nil {
+			reportError("Failed to rotate log file %s: %v", f.path, err)
+		}
+	}
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.milling.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *rotatingFile) shouldRotate(now time.Time, n int) bool {
+	if f.rotation.MaxSize > 0 && f.size > 0 && f.size+int64(n) > f.rotation.MaxSize {
+		return true
+	}
+	if f.rotation.Daily {
+		y1, m1, d1 := f.openedAt.Date()
+		y2, m2, d2 := now.Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// rotate closes the current file, moves it out of the way and opens a fresh one.
+// Backups are named after the time their file was opened, so a daily backup
+// carries the date of the day it covers.
+func (f *rotatingFile) rotate(now time.Time) error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file '%s': %w", f.path, err)
+		}
+		f.file = nil
+	}
+
+	if f.rotation.Symlink {
+		if err := f.openNamed(f.backupName(now), now); err != nil {
+			return err
+		}
+	} else {
+		if err := os.Rename(f.path, f.backupName(f.openedAt)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rename log file '%s': %w", f.path, err)
+		}
+		if err := f.openNamed(f.path, now); err != nil {
+			return err
+		}
+	}
+
+	f.startMill()
+	return nil
+}
+
+// openNamed opens name for appending and makes it the current file.
+func (f *rotatingFile) openNamed(name string, now time.Time) error {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file '%s': %w", name, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file '%s': %w", name, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.current.Store(filepath.Base(name))
+	f.openedAt = now
+	if f.size > 0 {
+		f.openedAt = info.ModTime()
+	}
+
+	if f.rotation.Symlink {
+		return f.link(name)
+	}
+	return nil
+}
+
+// link atomically points the configured path at name. A regular file left
+// at the path by an earlier, non-symlinked run is kept as a backup.
+func (f *rotatingFile) link(name string) error {
+	if info, err := os.Lstat(f.path); err == nil && info.Mode().IsRegular() {
+		if err := os.Rename(f.path, f.backupName(info.ModTime())); err != nil {
+			return fmt.Errorf("failed to move log file '%s' aside: %w", f.path, err)
+		}
+	}
+
+	tmp := f.path + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(name), tmp); err != nil {
+		return fmt.Errorf("failed to create symlink '%s': %w", tmp, err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to replace symlink '%s': %w", f.path, err)
+	}
+	return nil
+}
+
+// backupName returns "<dir>/<name>-<timestamp><ext>" for the configured path,
+// adding a counter if such a file already exists.
+func (f *rotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.nameParts()
+	base := filepath.Join(dir, prefix+t.Format(backupTimeFormat))
+
+	name := base + ext
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s.%d%s", base, i, ext)
+	}
+	return name
+}
+
+func (f *rotatingFile) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(f.path)
+	base := filepath.Base(f.path)
+	ext = filepath.Ext(base)
+	prefix = strings.TrimSuffix(base, ext) + "-"
+	return dir, prefix, ext
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+type backupFile struct {
+	name      string
+	timestamp time.Time
+}
+
+// backups lists rotated files, newest first.
+func (f *rotatingFile) backups() ([]backupFile, error) {
+	dir, prefix, ext := f.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ext)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+
+		t, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{name: filepath.Join(dir, name), timestamp: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+	return backups, nil
+}
+
+func (f *rotatingFile) startMill() {
+	f.milling.Add(1)
+	go func() {
+		defer f.milling.Done()
+		f.mill()
+	}()
+}
+
+// mill applies MaxBackups and MaxAge and compresses the remaining backups.
+func (f *rotatingFile) mill() {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		reportError("Failed to list rotated log files for %s: %v", f.path, err)
+		return
+	}
+
+	cutoff := time.Time{}
+	if f.rotation.MaxAge > 0 {
+		cutoff = time.Now().Add(-f.rotation.MaxAge)
+	}
+
+	kept := 0
+	for _, backup := range backups {
+		if filepath.Base(backup.name) == f.current.Load() {
+			continue
+		}
+
+		expired := f.rotation.MaxBackups > 0 && kept >= f.rotation.MaxBackups
+		expired = expired || (!cutoff.IsZero() && backup.timestamp.Before(cutoff))
+		if expired {
+			os.Remove(backup.name)
+			continue
+		}
+		kept++
+
+		if f.rotation.Compress && !strings.HasSuffix(backup.name, ".gz") {
+			if err := compressFile(backup.name); err != nil {
+				reportError("Failed to compress rotated log file %s: %v", backup.name, err)
+			}
+		}
+	}
+}
+
+// compressFile gzips name into name.gz and removes the original.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	return os.Remove(name)
+}
@@ -0,0 +1,215 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestFile opens a rotating file in a temporary directory and closes it
+// when the test ends.
+func openTestFile(t *testing.T, rotation Rotation) *rotatingFile {
+	t.Helper()
+	f, err := openRotatingFile(filepath.Join(t.TempDir(), "app.log"), rotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func writeString(t *testing.T, f *rotatingFile, s string) {
+	t.Helper()
+	if _, err := f.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// backupNames returns the base names of the rotated files, oldest first.
+func backupNames(t *testing.T, f *rotatingFile) []string {
+	t.Helper()
+	f.milling.Wait()
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, backup := range backups {
+		if name := filepath.Base(backup.name); name != f.current.Load() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotateBySize(t *testing.T) {
+	f := openTestFile(t, Rotation{MaxSize: 10})
+
+	start := time.Now().Add(-time.Hour)
+	for i, s := range []string{"first\n", "second\n", "third\n"} { // each write takes the file past 10 bytes
+		f.openedAt = start.Add(time.Duration(i) * time.Minute)
+		writeString(t, f, s)
+	}
+
+	names := backupNames(t, f)
+	if len(names) != 2 {
+		t.Fatalf("backups = %v, want 2", names)
+	}
+	dir := filepath.Dir(f.path)
+	if got := readFile(t, filepath.Join(dir, names[0])); got != "first\n" {
+		t.Errorf("oldest backup = %q, want %q", got, "first\n")
+	}
+	if got := readFile(t, filepath.Join(dir, names[1])); got != "second\n" {
+		t.Errorf("newest backup = %q, want %q", got, "second\n")
+	}
+	if got := readFile(t, f.path); got != "third\n" {
+		t.Errorf("current file = %q, want %q", got, "third\n")
+	}
+}
+
+func TestRotateDailyNamesBackupAfterItsDay(t *testing.T) {
+	f := openTestFile(t, Rotation{Daily: true})
+
+	writeString(t, f, "today\n")
+	if names := backupNames(t, f); len(names) != 0 {
+		t.Fatalf("rotated within the day: %v", names)
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	f.openedAt = yesterday
+	writeString(t, f, "after midnight\n")
+
+	names := backupNames(t, f)
+	if len(names) != 1 {
+		t.Fatalf("backups = %v, want 1", names)
+	}
+	want := "app-" + yesterday.Format("2006-01-02T")
+	if !strings.HasPrefix(names[0], want) {
+		t.Errorf("backup = %s, want it named after %s", names[0], yesterday.Format("2006-01-02"))
+	}
+	if got := readFile(t, f.path); got != "after midnight\n" {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+func TestRotateMaxBackups(t *testing.T) {
+	f := openTestFile(t, Rotation{MaxSize: 1, MaxBackups: 2})
+
+	start := time.Now().Add(-time.Hour)
+	for i, s := range []string{"a", "b", "c", "d", "e"} {
+		f.openedAt = start.Add(time.Duration(i) * time.Minute)
+		writeString(t, f, s)
+	}
+
+	names := backupNames(t, f)
+	if len(names) != 2 {
+		t.Fatalf("backups = %v, want 2", names)
+	}
+	dir := filepath.Dir(f.path)
+	got := readFile(t, filepath.Join(dir, names[0])) + readFile(t, filepath.Join(dir, names[1]))
+	if got != "cd" {
+		t.Errorf("kept backups hold %q, want the newest, %q", got, "cd")
+	}
+}
+
+func TestRotateMaxAge(t *testing.T) {
+	f := openTestFile(t, Rotation{MaxAge: 24 * time.Hour})
+	f.milling.Wait()
+
+	dir := filepath.Dir(f.path)
+	old := filepath.Join(dir, "app-"+time.Now().Add(-48*time.Hour).Format(backupTimeFormat)+".log")
+	recent := filepath.Join(dir, "app-"+time.Now().Add(-time.Hour).Format(backupTimeFormat)+".log")
+	unrelated := filepath.Join(dir, "other.log")
+	for _, name := range []string{old, recent, unrelated} {
+		if err := os.WriteFile(name, []byte("x"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.mill()
+
+	if fileExists(old) {
+		t.Errorf("%s was kept past MaxAge", filepath.Base(old))
+	}
+	if !fileExists(recent) {
+		t.Errorf("%s was removed", filepath.Base(recent))
+	}
+	if !fileExists(unrelated) {
+		t.Errorf("unrelated file was removed")
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	f := openTestFile(t, Rotation{MaxSize: 1, Compress: true})
+
+	writeString(t, f, "compressed\n")
+	writeString(t, f, "current\n")
+
+	names := backupNames(t, f)
+	if len(names) != 1 || !strings.HasSuffix(names[0], ".log.gz") {
+		t.Fatalf("backups = %v, want one .log.gz", names)
+	}
+
+	file, err := os.Open(filepath.Join(filepath.Dir(f.path), names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "compressed\n" {
+		t.Errorf("decompressed backup = %q", data)
+	}
+}
+
+func TestRotateSymlink(t *testing.T) {
+	f := openTestFile(t, Rotation{Daily: true, Symlink: true})
+	writeString(t, f, "day one\n")
+
+	first, err := os.Readlink(f.path)
+	if err != nil {
+		t.Fatalf("path is not a symlink: %v", err)
+	}
+	if first != f.current.Load() {
+		t.Errorf("symlink points at %s, want the current file %s", first, f.current.Load())
+	}
+
+	f.openedAt = time.Now().AddDate(0, 0, -1)
+	writeString(t, f, "day two\n")
+
+	second, err := os.Readlink(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || second != f.current.Load() {
+		t.Errorf("symlink points at %s after rotation, want the new file %s", second, f.current.Load())
+	}
+	dir := filepath.Dir(f.path)
+	if got := readFile(t, filepath.Join(dir, first)); got != "day one\n" {
+		t.Errorf("previous file = %q", got)
+	}
+	if got := readFile(t, f.path); got != "day two\n" {
+		t.Errorf("file through symlink = %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SamplingKey int
+
+const (
+	// SampleByCallSite groups entries by the package, file and line that
+	// logged them.
+	SampleByCallSite SamplingKey = iota
+	// SampleByTemplate groups entries by their format string, or by their
+	// message for the non-f functions.
+	SampleByTemplate
+)
+
+// Sampling limits how many entries with the same key are written per
+// Interval: the First entries are written, then every Thereafter-th one.
+// PANIC entries are never dropped. The zero value disables sampling.
+type Sampling struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int // 0 drops everything after First
+	By         SamplingKey
+
+	// SummaryInterval is how often a WARN entry reporting the number of
+	// suppressed entries is written to every sink. Defaults to Interval.
+	SummaryInterval time.Duration
+}
+
+// maxSummarizedKeys bounds the number of keys named in a summary entry.
+const maxSummarizedKeys = 5
+
+type sampleCounter struct {
+	windowStart time.Time
+	count       int
+}
+
+var (
+	sampling           Sampling
+	samplingMu         sync.Mutex
+	samplingCounters   = map[string]*sampleCounter{}
+	samplingSuppressed = map[string]int{}
+	samplingStop       chan struct{}
+	samplingDone       chan struct{}
+)
+
+// SetSampling replaces the sampling configuration. Pending suppressed counts
+// are summarized before the new configuration takes effect.
+func SetSampling(config Sampling) {
+	samplingMu.Lock()
+	stop, done := samplingStop, samplingDone
+	samplingStop, samplingDone = nil, nil
+	samplingMu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+
+	sampling = config
+	samplingCounters = map[string]*sampleCounter{}
+	samplingSuppressed = map[string]int{}
+
+	if config.Interval <= 0 {
+		return
+	}
+
+	interval := config.SummaryInterval
+	if interval <= 0 {
+		interval = config.Interval
+	}
+	samplingStop = make(chan struct{})
+	samplingDone = make(chan struct{})
+	go summarizeSampling(interval, samplingStop, samplingDone)
+}
+
+// sampleEntry reports whether the entry should be written.
+func sampleEntry(entry *Entry) bool {
+	if entry.Level == PANIC {
+		return true
+	}
+
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+
+	if sampling.Interval <= 0 {
+		return true
+	}
+
+	key := callSite(entry)
+	if sampling.By == SampleByTemplate {
+		key = entry.template
+	}
+
+	counter := samplingCounters[key]
+	if counter == nil || entry.Time.Sub(counter.windowStart) >= sampling.Interval {
+		counter = &sampleCounter{windowStart: entry.Time}
+		samplingCounters[key] = counter
+	}
+	counter.count++
+
+	if counter.count <= sampling.First {
+		return true
+	}
+	if sampling.Thereafter > 0 && (counter.count-sampling.First)%sampling.Thereafter == 0 {
+		return true
+	}
+
+	samplingSuppressed[key]++
+	return false
+}
+
+// callSite returns "<package>/<file>:<line>" for the entry, as File only
+// holds the base name and would group same-named files of different
+// packages.
+func callSite(entry *Entry) string {
+	site := entry.File + ":" + strconv.Itoa(entry.Line)
+	if entry.pkg != "" {
+		site = entry.pkg + "/" + site
+	}
+	return site
+}
+
+func summarizeSampling(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			writeSamplingSummary()
+		case <-stop:
+			writeSamplingSummary()
+			return
+		}
+	}
+}
+
+// writeSamplingSummary writes one WARN entry with the number of entries
+// suppressed since the last summary, and forgets expired counters.
+func writeSamplingSummary() {
+	samplingMu.Lock()
+	suppressed := samplingSuppressed
+	samplingSuppressed = map[string]int{}
+
+	now := time.Now()
+	for key, counter := range samplingCounters {
+		if now.Sub(counter.windowStart) >= sampling.Interval {
+			delete(samplingCounters, key)
+		}
+	}
+	samplingMu.Unlock()
+
+	total := 0
+	keys := make([]string, 0, len(suppressed))
+	for key, count := range suppressed {
+		total += count
+		keys = append(keys, key)
+	}
+	if total == 0 {
+		return
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return suppressed[keys[i]] > suppressed[keys[j]]
+	})
+	if len(keys) > maxSummarizedKeys {
+		keys = keys[:maxSummarizedKeys]
+	}
+	top := make([]string, len(keys))
+	for i, key := range keys {
+		top[i] = fmt.Sprintf("%s (%d)", key, suppressed[key])
+	}
+
+	deliver(&Entry{
+		Time:    now,
+		Level:   WARN,
+		Message: fmt.Sprintf("Suppressed %d log messages", total),
+		Fields: []Field{
+			{Key: "suppressed", Value: total},
+			{Key: "top", Value: strings.Join(top, ", ")},
+		},
+		File: "???",
+	})
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+// setSampling applies config for the rest of the test.
+func setSampling(t *testing.T, config Sampling) {
+	t.Helper()
+	SetSampling(config)
+	t.Cleanup(func() { SetSampling(Sampling{}) })
+}
+
+func TestSamplingFirstThereafter(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		first      int
+		thereafter int
+		want       []int
+	}{
+		{"first only", 3, 0, []int{1, 2, 3}},
+		{"every third after two", 2, 3, []int{1, 2, 5, 8}},
+		{"every entry", 0, 1, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setSampling(t, Sampling{Interval: time.Hour, First: tt.first, Thereafter: tt.thereafter})
+
+			var written []int
+			now := time.Now()
+			for i := 1; i <= 10; i++ {
+				entry := &Entry{Time: now, Level: INFO, File: "a.go", Line: 1, pkg: "example.com/a"}
+				if sampleEntry(entry) {
+					written = append(written, i)
+				}
+			}
+			if !slices.Equal(written, tt.want) {
+				t.Errorf("written = %v, want %v", written, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplingKeys(t *testing.T) {
+	now := time.Now()
+	site := func(pkg, file string, line int, template string) *Entry {
+		return &Entry{Time: now, Level: INFO, File: file, Line: line, pkg: pkg, template: template}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		by      SamplingKey
+		entries []*Entry
+		want    []bool
+	}{
+		{
+			"same call site",
+			SampleByCallSite,
+			[]*Entry{site("example.com/a", "log.go", 1, "x"), site("example.com/a", "log.go", 1, "y")},
+			[]bool{true, false},
+		},
+		{
+			"same file name in another package",
+			SampleByCallSite,
+			[]*Entry{site("example.com/a", "log.go", 1, "x"), site("example.com/b", "log.go", 1, "x")},
+			[]bool{true, true},
+		},
+		{
+			"another line",
+			SampleByCallSite,
+			[]*Entry{site("example.com/a", "log.go", 1, "x"), site("example.com/a", "log.go", 2, "x")},
+			[]bool{true, true},
+		},
+		{
+			"same template from two call sites",
+			SampleByTemplate,
+			[]*Entry{site("example.com/a", "log.go", 1, "x"), site("example.com/b", "main.go", 9, "x")},
+			[]bool{true, false},
+		},
+		{
+			"PANIC is never dropped",
+			SampleByCallSite,
+			[]*Entry{
+				site("example.com/a", "log.go", 1, "x"),
+				{Time: now, Level: PANIC, File: "log.go", Line: 1, pkg: "example.com/a"},
+			},
+			[]bool{true, true},
+		},
+		{
+			"new window",
+			SampleByCallSite,
+			[]*Entry{
+				site("example.com/a", "log.go", 1, "x"),
+				{Time: now.Add(time.Hour), Level: INFO, File: "log.go", Line: 1, pkg: "example.com/a"},
+			},
+			[]bool{true, true},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			setSampling(t, Sampling{Interval: time.Hour, First: 1, By: tt.by})
+			for i, entry := range tt.entries {
+				if got := sampleEntry(entry); got != tt.want[i] {
+					t.Errorf("entry %d written = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSamplingSummary(t *testing.T) {
+	sink := capture(t)
+	setSampling(t, Sampling{Interval: time.Hour, First: 2, Thereafter: 3})
+
+	for i := 0; i < 10; i++ {
+		Infof("sampled summary %d", i)
+	}
+
+	if got := len(sink.all()); got != 4 {
+		t.Fatalf("wrote %d of 10 entries, want 4: %q", got, sink.messages())
+	}
+
+	// Replacing the configuration summarizes what was suppressed.
+	SetSampling(Sampling{})
+
+	entries := sink.all()
+	summary := entries[len(entries)-1]
+	if summary.Level != WARN || summary.Message != "Suppressed 6 log messages" {
+		t.Fatalf("summary = %v %q", summary.Level, summary.Message)
+	}
+	if n, _ := fieldValue(summary, "suppressed"); n != 6 {
+		t.Errorf("suppressed = %v, want 6", n)
+	}
+	top, _ := fieldValue(summary, "top")
+	if s, _ := top.(string); !regexp.MustCompile(`^\S+\.go:\d+ \(6\)$`).MatchString(s) {
+		t.Errorf("top = %v, want the call site with 6 suppressed entries", top)
+	}
+}
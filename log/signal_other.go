@@ -0,0 +1,8 @@
+//go:build !unix
+
+package log
+
+// HandleLevelSignals does nothing on platforms without SIGUSR1 and SIGUSR2.
+func HandleLevelSignals() (stop func()) {
+	return func() {}
+}
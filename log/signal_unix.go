@@ -0,0 +1,61 @@
+//go:build unix
+
+package log
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleLevelSignals lets operators change the level of a running process:
+// SIGUSR1 toggles between DEBUG and the level set before, and SIGUSR2 cycles
+// through ERROR, WARN, INFO and DEBUG. The returned function stops handling
+// the signals. On platforms without these signals it does nothing.
+func HandleLevelSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		toggled := INFO // level restored by the next SIGUSR1 out of DEBUG
+		for {
+			select {
+			case sig := <-signals:
+				switch sig {
+				case syscall.SIGUSR1:
+					if level := GetLevel(); level != DEBUG {
+						toggled = level
+						SetLevel(DEBUG)
+					} else {
+						SetLevel(toggled)
+					}
+				case syscall.SIGUSR2:
+					SetLevel(nextLevel(GetLevel()))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// nextLevel returns the level after level in the cycle ERROR, WARN, INFO,
+// DEBUG used by SIGUSR2.
+func nextLevel(level LogLevel) LogLevel {
+	switch level {
+	case ERROR:
+		return WARN
+	case WARN:
+		return INFO
+	case INFO:
+		return DEBUG
+	default:
+		return ERROR
+	}
+}
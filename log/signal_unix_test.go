@@ -0,0 +1,46 @@
+//go:build unix
+
+package log
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestNextLevel(t *testing.T) {
+	for _, tt := range []struct {
+		level, want LogLevel
+	}{
+		{ERROR, WARN},
+		{WARN, INFO},
+		{INFO, DEBUG},
+		{DEBUG, ERROR},
+		{PANIC, ERROR},
+		{REQUEST, ERROR},
+	} {
+		if got := nextLevel(tt.level); got != tt.want {
+			t.Errorf("nextLevel(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestHandleLevelSignals(t *testing.T) {
+	keepLevels(t)
+	SetLevel(WARN)
+	stop := HandleLevelSignals()
+	defer stop()
+
+	signal := func(sig syscall.Signal) {
+		t.Helper()
+		if err := syscall.Kill(syscall.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signal(syscall.SIGUSR1)
+	waitForLevel(t, DEBUG)
+	signal(syscall.SIGUSR1)
+	waitForLevel(t, WARN)
+	signal(syscall.SIGUSR2)
+	waitForLevel(t, INFO)
+}
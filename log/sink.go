@@ -0,0 +1,242 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sink is a destination for log entries. Write receives the entry together
+// with the line produced by the sink's encoder, without a trailing newline.
+type Sink interface {
+	Write(entry *Entry, line string) error
+	Flush() error
+	Close() error
+}
+
+// SinkOption configures how entries are routed to a sink registered with AddSink.
+type SinkOption func(*registeredSink)
+
+// WithLevel sets the most verbose level written to the sink. Without it the
+// sink follows the global level set by SetLevel, or the SetLevelSpec override
+// for the entry's logger or package.
+func WithLevel(level LogLevel) SinkOption {
+	return func(s *registeredSink) {
+		s.level = level
+		s.followGlobal = false
+	}
+}
+
+// WithEncoder sets the encoder used to format entries for the sink.
+// Defaults to TextEncoder.
+func WithEncoder(encoder Encoder) SinkOption {
+	return func(s *registeredSink) {
+		s.encoder = encoder
+	}
+}
+
+type registeredSink struct {
+	name         string
+	sink         Sink
+	encoder      Encoder
+	level        LogLevel
+	followGlobal bool
+}
+
+func (s *registeredSink) enabled(entry *Entry) bool {
+	return levelEnabled(entry, s.level, s.followGlobal)
+}
+
+// levelEnabled reports whether an entry passes level, or the entry's own
+// threshold if followGlobal is set.
+func levelEnabled(entry *Entry, level LogLevel, followGlobal bool) bool {
+	threshold := level
+	if followGlobal {
+		threshold = entry.threshold()
+	}
+	level = entry.Level
+	if level == REQUEST {
+		level = INFO
+	}
+	return level <= threshold
+}
+
+var (
+	sinks   []*registeredSink
+	sinksMu sync.RWMutex
+)
+
+// AddSink registers a sink under name. A sink already registered under the
+// same name is flushed, closed and replaced in place.
+func AddSink(name string, sink Sink, opts ...SinkOption) {
+	s := &registeredSink{
+		name:         name,
+		sink:         sink,
+		encoder:      TextEncoder,
+		followGlobal: true,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	sinksMu.Lock()
+	var old *registeredSink
+	updated := make([]*registeredSink, 0, len(sinks)+1)
+	for _, existing := range sinks {
+		if existing.name == name {
+			old = existing
+			updated = append(updated, s)
+			continue
+		}
+		updated = append(updated, existing)
+	}
+	if old == nil {
+		updated = append(updated, s)
+	}
+	sinks = updated
+	sinksMu.Unlock()
+
+	if old != nil {
+		closeSink(old)
+	}
+}
+
+// RemoveSink flushes, closes and unregisters the sink registered under name.
+func RemoveSink(name string) error {
+	sinksMu.Lock()
+	var removed *registeredSink
+	updated := make([]*registeredSink, 0, len(sinks))
+	for _, existing := range sinks {
+		if existing.name == name {
+			removed = existing
+			continue
+		}
+		updated = append(updated, existing)
+	}
+	sinks = updated
+	sinksMu.Unlock()
+
+	if removed == nil {
+		return fmt.Errorf("no sink registered as '%s'", name)
+	}
+	return closeSink(removed)
+}
+
+// SinkNames returns the names of the registered sinks in registration order.
+func SinkNames() []string {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+
+	names := make([]string, len(sinks))
+	for i, s := range sinks {
+		names[i] = s.name
+	}
+	return names
+}
+
+func closeSink(s *registeredSink) error {
+	if err := s.sink.Flush(); err != nil {
+		s.sink.Close()
+		return fmt.Errorf("failed to flush sink '%s': %w", s.name, err)
+	}
+	if err := s.sink.Close(); err != nil {
+		return fmt.Errorf("failed to close sink '%s': %w", s.name, err)
+	}
+	return nil
+}
+
+// writeSinks encodes and writes an entry to every sink in current whose
+// level allows it.
+func writeSinks(current []*registeredSink, entry *Entry) {
+	for _, s := range current {
+		if !s.enabled(entry) {
+			continue
+		}
+		if err := s.sink.Write(entry, s.encoder.Encode(entry)); err != nil {
+			reportError("Failed to write to log sink %s: %v", s.name, err)
+		}
+	}
+}
+
+// WriterSink writes one line per entry to an io.Writer.
+type WriterSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewWriterSink returns a sink writing plain lines to w. The writer is
+// synced on Flush if it supports it, but never closed.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(_ *Entry, line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintln(s.w, line)
+	return err
+}
+
+func (s *WriterSink) Flush() error {
+	if syncer, ok := s.w.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink writes to a file on disk, rotating it according to its Rotation.
+type FileSink struct {
+	mu   sync.Mutex
+	file *rotatingFile
+}
+
+// NewFileSink creates the file's directory if needed and opens it for appending.
+func NewFileSink(filePath string, rotation Rotation) (*FileSink, error) {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0750); err != nil { // Changed permissions slightly
+		return nil, fmt.Errorf("failed to create log directory '%s': %w", dir, err)
+	}
+
+	file, err := openRotatingFile(filePath, rotation)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Write(_ *Entry, line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.file, "%s\n", line)
+	return err
+}
+
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file.file == nil {
+		return nil
+	}
+	return s.file.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+func init() {
+	console := NewConsoleSink(os.Stdout)
+	AddSink("console", console, WithEncoder(console.Encoder()))
+}
@@ -0,0 +1,178 @@
+package log
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// closingSink records entries and counts the calls to Flush and Close.
+type closingSink struct {
+	captureSink
+	flushErr error
+	flushed  int
+	closed   int
+}
+
+func (s *closingSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushed++
+	return s.flushErr
+}
+
+func (s *closingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed++
+	return nil
+}
+
+func (s *closingSink) calls() (flushed, closed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushed, s.closed
+}
+
+// addTestSink registers sink under a name prefixed with the test's and
+// removes it when the test ends.
+func addTestSink(t *testing.T, name string, sink Sink, opts ...SinkOption) string {
+	t.Helper()
+	name = t.Name() + "-" + name
+	AddSink(name, sink, opts...)
+	t.Cleanup(func() { RemoveSink(name) })
+	return name
+}
+
+// testSinkNames returns the registered sink names belonging to the test.
+func testSinkNames(t *testing.T) []string {
+	var names []string
+	for _, name := range SinkNames() {
+		if strings.HasPrefix(name, t.Name()+"-") {
+			names = append(names, strings.TrimPrefix(name, t.Name()+"-"))
+		}
+	}
+	return names
+}
+
+func TestAddSinkReplacesInPlace(t *testing.T) {
+	first, old, last := &closingSink{}, &closingSink{}, &closingSink{}
+	addTestSink(t, "first", first, WithLevel(DEBUG))
+	addTestSink(t, "middle", old, WithLevel(DEBUG))
+	addTestSink(t, "last", last, WithLevel(DEBUG))
+
+	replacement := &closingSink{}
+	addTestSink(t, "middle", replacement, WithLevel(DEBUG))
+
+	if flushed, closed := old.calls(); flushed != 1 || closed != 1 {
+		t.Errorf("replaced sink flushed %d and closed %d times, want once each", flushed, closed)
+	}
+	if got, want := testSinkNames(t), []string{"first", "middle", "last"}; !slices.Equal(got, want) {
+		t.Errorf("sinks = %q, want %q", got, want)
+	}
+
+	Warn("after replacing")
+	if got := old.messages(); len(got) != 0 {
+		t.Errorf("replaced sink received %q", got)
+	}
+	for _, s := range []*closingSink{first, replacement, last} {
+		if got := s.messages(); !slices.Equal(got, []string{"after replacing"}) {
+			t.Errorf("sink received %q", got)
+		}
+	}
+	if _, closed := first.calls(); closed != 0 {
+		t.Error("replacing a sink closed another one")
+	}
+}
+
+func TestRemoveSink(t *testing.T) {
+	if err := RemoveSink("no-such-sink"); err == nil || err.Error() != "no sink registered as 'no-such-sink'" {
+		t.Errorf("RemoveSink() = %v, want an unknown sink error", err)
+	}
+
+	s := &closingSink{}
+	name := addTestSink(t, "removed", s)
+	if err := RemoveSink(name); err != nil {
+		t.Fatal(err)
+	}
+	if flushed, closed := s.calls(); flushed != 1 || closed != 1 {
+		t.Errorf("removed sink flushed %d and closed %d times, want once each", flushed, closed)
+	}
+	if names := testSinkNames(t); len(names) != 0 {
+		t.Errorf("sinks = %q after removing", names)
+	}
+	if err := RemoveSink(name); err == nil {
+		t.Error("removing a sink twice succeeded")
+	}
+
+	// A sink failing to flush is still closed and unregistered.
+	failing := &closingSink{flushErr: errors.New("disk full")}
+	name = addTestSink(t, "failing", failing)
+	err := RemoveSink(name)
+	if err == nil || !strings.Contains(err.Error(), "failed to flush sink") || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("RemoveSink() = %v, want the flush error", err)
+	}
+	if _, closed := failing.calls(); closed != 1 {
+		t.Error("sink not closed after its flush failed")
+	}
+	if names := testSinkNames(t); len(names) != 0 {
+		t.Errorf("sinks = %q after removing", names)
+	}
+}
+
+func TestSinkLevels(t *testing.T) {
+	keepLevels(t)
+	SetLevel(INFO)
+	setLevelSpec(t, "worker=debug")
+
+	global, warn, info, debug := &closingSink{}, &closingSink{}, &closingSink{}, &closingSink{}
+	addTestSink(t, "global", global)
+	addTestSink(t, "warn", warn, WithLevel(WARN))
+	addTestSink(t, "info", info, WithLevel(INFO))
+	addTestSink(t, "debug", debug, WithLevel(DEBUG))
+
+	Error("error")
+	Warn("warn")
+	Info("info")
+	Request("request")
+	Debug("debug")
+	Default().Named("worker").Debug("worker debug")
+
+	for _, tt := range []struct {
+		name string
+		sink *closingSink
+		want []string
+	}{
+		// Sinks without WithLevel follow the global level and the level spec.
+		{"global", global, []string{"error", "warn", "info", "request", "worker debug"}},
+		{"warn", warn, []string{"error", "warn"}},
+		// REQUEST entries are filtered as INFO.
+		{"info", info, []string{"error", "warn", "info", "request"}},
+		// Debug is a no-op unless the global level or the spec enables it,
+		// whatever the sinks' levels.
+		{"debug", debug, []string{"error", "warn", "info", "request", "worker debug"}},
+	} {
+		if got := tt.sink.messages(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s sink received %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	SetLevel(DEBUG)
+	Debug("debug at debug")
+	if got := debug.messages(); !slices.Contains(got, "debug at debug") {
+		t.Error("debug sink missed a DEBUG entry at the DEBUG level")
+	}
+	if got := info.messages(); slices.Contains(got, "debug at debug") {
+		t.Error("sink with its own INFO level followed SetLevel")
+	}
+
+	SetLevel(ERROR)
+	Warn("warn at error")
+	if got := global.messages(); slices.Contains(got, "warn at error") {
+		t.Error("sink following the global level ignored SetLevel")
+	}
+	if got := warn.messages(); !slices.Contains(got, "warn at error") {
+		t.Error("sink with its own level followed SetLevel")
+	}
+}
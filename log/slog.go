@@ -0,0 +1,173 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Additional slog levels for the LogLevels slog has no equivalent for.
+const (
+	SlogLevelRequest = slog.LevelInfo + 1
+	SlogLevelPanic   = slog.LevelError + 4
+)
+
+// SlogHandler is a slog.Handler that writes records through this package,
+// so they share the console format, deduplication and file output with the
+// rest of the logger. Records at SlogLevelPanic panic after being written.
+//
+//	slog.SetDefault(slog.New(log.NewSlogHandler(nil)))
+type SlogHandler struct {
+	name   string
+	fields []Field
+	prefix string
+}
+
+// NewSlogHandler returns a handler that carries the name and fields of the
+// given logger. A nil logger uses Default().
+func NewSlogHandler(logger *Logger) *SlogHandler {
+	if logger == nil {
+		logger = std
+	}
+	return &SlogHandler{name: logger.name, fields: logger.fields}
+}
+
+// LevelFromSlog maps a slog level onto the closest LogLevel.
+func LevelFromSlog(level slog.Level) LogLevel {
+	switch {
+	case level >= SlogLevelPanic:
+		return PANIC
+	case level >= slog.LevelError:
+		return ERROR
+	case level >= slog.LevelWarn:
+		return WARN
+	case level >= SlogLevelRequest:
+		return REQUEST
+	case level >= slog.LevelInfo:
+		return INFO
+	default:
+		return DEBUG
+	}
+}
+
+// LevelToSlog maps a LogLevel onto the slog level used by SlogHandler.
+func LevelToSlog(level LogLevel) slog.Level {
+	switch level {
+	case PANIC:
+		return SlogLevelPanic
+	case ERROR:
+		return slog.LevelError
+	case WARN:
+		return slog.LevelWarn
+	case REQUEST:
+		return SlogLevelRequest
+	case DEBUG:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Enabled mirrors the package-level functions: only DEBUG depends on the
+// current level and SetLevelSpec overrides.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if LevelFromSlog(level) == DEBUG {
+		return debugEnabled()
+	}
+	return true
+}
+
+// Handle writes the record. If ctx carries an OpenTelemetry span, its trace
+// and span IDs are added as with Logger.WithContext.
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make([]Field, len(h.fields), len(h.fields)+record.NumAttrs())
+	copy(fields, h.fields)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, attr)
+		return true
+	})
+
+	span := trace.SpanFromContext(ctx)
+	file, line, pkg := "???", 0, ""
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		file, line, pkg = filepath.Base(frame.File), frame.Line, packageOf(frame.Function)
+	}
+
+	timestamp := record.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	entry := &Entry{
+		Time:    timestamp,
+		Level:   LevelFromSlog(record.Level),
+		Message: record.Message,
+		Fields:  withStack(LevelFromSlog(record.Level), traceFields(span, fields)),
+		File:    file,
+		Line:    line,
+
+		template: record.Message,
+		pkg:      pkg,
+	}
+	entry.levelOverride, entry.hasOverride = levelOverride(h.name, pkg, file)
+	addSpanEvent(span, entry)
+
+	if entry.Level == DEBUG && entry.threshold() < DEBUG {
+		return nil
+	}
+	dispatch(entry)
+
+	if entry.Level == PANIC {
+		panic(entry.Message)
+	}
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	fields := make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(fields, h.fields)
+	for _, attr := range attrs {
+		fields = appendSlogAttr(fields, h.prefix, attr)
+	}
+	return &SlogHandler{name: h.name, fields: fields, prefix: h.prefix}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{name: h.name, fields: h.fields, prefix: h.prefix + name + "."}
+}
+
+// appendSlogAttr flattens an attribute into fields, joining group names with dots.
+func appendSlogAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		group := attr.Value.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range group {
+			fields = appendSlogAttr(fields, prefix, member)
+		}
+		return fields
+	}
+
+	return append(fields, Field{Key: prefix + attr.Key, Value: attr.Value.Any()})
+}
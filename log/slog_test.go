@@ -0,0 +1,100 @@
+package log
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+func TestSlogHandler(t *testing.T) {
+	sink := capture(t)
+
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		if strings.HasSuffix(t.Name(), "/zero-time") {
+			// Entries always carry a time, so records without one are
+			// stamped with the time they were handled.
+			t.Skip("SlogHandler stamps records that have no time")
+		}
+		return NewSlogHandler(nil)
+	}, func(t *testing.T) map[string]any {
+		entries := sink.all()
+		if len(entries) == 0 {
+			t.Fatal("nothing was logged")
+		}
+		return slogResult(entries[len(entries)-1])
+	})
+}
+
+// slogResult converts an entry into the map slogtest expects, nesting the
+// dotted keys of grouped attributes.
+func slogResult(entry *Entry) map[string]any {
+	result := map[string]any{
+		slog.TimeKey:    entry.Time,
+		slog.LevelKey:   entry.Level,
+		slog.MessageKey: entry.Message,
+	}
+	for _, field := range entry.Fields {
+		m := result
+		keys := strings.Split(field.Key, ".")
+		for _, group := range keys[:len(keys)-1] {
+			sub, ok := m[group].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				m[group] = sub
+			}
+			m = sub
+		}
+		m[keys[len(keys)-1]] = field.Value
+	}
+	return result
+}
+
+func TestLevelFromSlog(t *testing.T) {
+	for _, tt := range []struct {
+		level slog.Level
+		want  LogLevel
+	}{
+		{slog.LevelDebug - 4, DEBUG},
+		{slog.LevelDebug, DEBUG},
+		{slog.LevelInfo - 1, DEBUG},
+		{slog.LevelInfo, INFO},
+		{SlogLevelRequest, REQUEST},
+		{slog.LevelWarn - 1, REQUEST},
+		{slog.LevelWarn, WARN},
+		{slog.LevelError - 1, WARN},
+		{slog.LevelError, ERROR},
+		{SlogLevelPanic - 1, ERROR},
+		{SlogLevelPanic, PANIC},
+		{SlogLevelPanic + 8, PANIC},
+	} {
+		if got := LevelFromSlog(tt.level); got != tt.want {
+			t.Errorf("LevelFromSlog(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestLevelToSlog(t *testing.T) {
+	for _, tt := range []struct {
+		level LogLevel
+		want  slog.Level
+	}{
+		{PANIC, SlogLevelPanic},
+		{ERROR, slog.LevelError},
+		{WARN, slog.LevelWarn},
+		{INFO, slog.LevelInfo},
+		{REQUEST, SlogLevelRequest},
+		{DEBUG, slog.LevelDebug},
+		{LogLevel(99), slog.LevelInfo},
+	} {
+		if got := LevelToSlog(tt.level); got != tt.want {
+			t.Errorf("LevelToSlog(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+		// Every level maps back onto itself.
+		if tt.level <= REQUEST {
+			if back := LevelFromSlog(tt.want); back != tt.level {
+				t.Errorf("LevelFromSlog(LevelToSlog(%v)) = %v", tt.level, back)
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SyslogFormat int
+
+const (
+	RFC5424 SyslogFormat = iota
+	RFC3164
+)
+
+type SyslogFraming int
+
+const (
+	// OctetCounted prefixes each message with its length (RFC 6587 3.4.1).
+	OctetCounted SyslogFraming = iota
+	// NonTransparent terminates each message with a newline (RFC 6587 3.4.2).
+	// Newlines within a message are written as "\n" so they do not end it.
+	NonTransparent
+)
+
+// Syslog facilities, as defined in RFC 5424.
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+	FacilityLocal1 = 17
+	FacilityLocal2 = 18
+	FacilityLocal3 = 19
+	FacilityLocal4 = 20
+	FacilityLocal5 = 21
+	FacilityLocal6 = 22
+	FacilityLocal7 = 23
+)
+
+var syslogSeverities = map[LogLevel]int{
+	PANIC:   2, // critical
+	ERROR:   3, // error
+	WARN:    4, // warning
+	INFO:    6, // informational
+	DEBUG:   7, // debug
+	REQUEST: 6, // informational
+}
+
+// structuredDataID is the SD-ID fields are written under in RFC 5424
+// messages. 32473 is the enterprise number reserved for documentation.
+const structuredDataID = "fields@32473"
+
+// SyslogConfig describes where and how a SyslogSink sends messages.
+type SyslogConfig struct {
+	// Network is "udp", "tcp", "unix" or "unixgram". When both Network and
+	// Address are empty the local syslog socket is used.
+	Network string
+	Address string
+	// TLSConfig enables TLS on "tcp" connections.
+	TLSConfig *tls.Config
+
+	Format  SyslogFormat
+	Framing SyslogFraming // stream connections only
+	// Facility defaults to FacilityUser; kernel messages (0) cannot be sent.
+	Facility int
+	// AppName defaults to the executable name.
+	AppName string
+	// Hostname defaults to os.Hostname.
+	Hostname string
+
+	// BufferSize is the number of messages held while disconnected; the
+	// oldest are dropped once it is full. Defaults to 1024.
+	BufferSize int
+	// ReconnectDelay is the minimum time between connection attempts. Defaults to 1s.
+	ReconnectDelay time.Duration
+	// Timeout bounds dialing and each write. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// SyslogSink sends entries to a syslog daemon. It formats messages itself, so
+// the encoder it is registered with is not used. Messages are sent by a
+// background goroutine, so a slow or unreachable daemon never blocks logging.
+type SyslogSink struct {
+	config SyslogConfig
+	pid    int
+	writer *reconnectingWriter
+}
+
+// syslogConn is a connection to the daemon and whether it is a stream,
+// whose messages need framing.
+type syslogConn struct {
+	net.Conn
+	stream bool
+}
+
+// NewSyslogSink connects to the configured syslog target. If only the
+// connection fails, the sink is returned along with the error: it can still
+// be registered, buffering messages and reconnecting later.
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	if config.Facility == 0 {
+		config.Facility = FacilityUser
+	}
+	if config.Facility < 0 || config.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility: %d", config.Facility)
+	}
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 1024
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	switch config.Network {
+	case "", "unixgram", "udp", "udp4", "udp6":
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", config.Network)
+	}
+
+	s := &SyslogSink{config: config, pid: os.Getpid()}
+	s.writer = &reconnectingWriter{
+		name:       "syslog",
+		unit:       "messages",
+		bufferSize: config.BufferSize,
+		batchSize:  1,
+		// Retries sending while disconnected.
+		interval:       config.ReconnectDelay,
+		reconnectDelay: config.ReconnectDelay,
+		dial:           s.dial,
+		send:           s.send,
+	}
+	if err := s.writer.start(); err != nil {
+		return s, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	if s.config.Network == "" && s.config.Address == "" {
+		return s.dialLocal()
+	}
+
+	var conn net.Conn
+	var err error
+	if s.config.TLSConfig != nil && strings.HasPrefix(s.config.Network, "tcp") {
+		dialer := &net.Dialer{Timeout: s.config.Timeout}
+		conn, err = tls.DialWithDialer(dialer, s.config.Network, s.config.Address, s.config.TLSConfig)
+	} else {
+		conn, err = net.DialTimeout(s.config.Network, s.config.Address, s.config.Timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stream := s.config.Network == "unix" || strings.HasPrefix(s.config.Network, "tcp")
+	return &syslogConn{Conn: conn, stream: stream}, nil
+}
+
+// dialLocal tries the usual local syslog sockets, datagram first.
+func (s *SyslogSink) dialLocal() (net.Conn, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			conn, err := net.DialTimeout(network, path, s.config.Timeout)
+			if err == nil {
+				return &syslogConn{Conn: conn, stream: network == "unix"}, nil
+			}
+		}
+	}
+	return nil, errors.New("no local syslog socket found")
+}
+
+// Write buffers the message for the sending goroutine.
+func (s *SyslogSink) Write(entry *Entry, _ string) error {
+	s.writer.write([]byte(s.format(entry)))
+	return nil
+}
+
+func (s *SyslogSink) send(conn net.Conn, batch [][]byte) error {
+	c := conn.(*syslogConn)
+	for _, msg := range batch {
+		c.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+		if _, err := c.Write(s.frame(c.stream, msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frame applies the configured framing to msg on stream connections.
+func (s *SyslogSink) frame(stream bool, msg []byte) []byte {
+	if !stream {
+		return msg
+	}
+	if s.config.Framing == NonTransparent {
+		return append(bytes.ReplaceAll(msg, []byte("\n"), []byte(`\n`)), '\n')
+	}
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+// Flush sends any buffered messages, reconnecting immediately if needed.
+func (s *SyslogSink) Flush() error {
+	return s.writer.flush()
+}
+
+// Close sends any buffered messages and closes the connection.
+func (s *SyslogSink) Close() error {
+	return s.writer.close()
+}
+
+func (s *SyslogSink) priority(level LogLevel) int {
+	return s.config.Facility*8 + syslogSeverities[level]
+}
+
+func (s *SyslogSink) format(entry *Entry) string {
+	if s.config.Format == RFC3164 {
+		return fmt.Sprintf(
+			"<%d>%s %s %s[%d]: %s%s",
+			s.priority(entry.Level),
+			entry.Time.Format(time.Stamp),
+			s.config.Hostname,
+			s.config.AppName, s.pid,
+			entry.Message, formatFields(entry.Fields, ""),
+		)
+	}
+
+	return fmt.Sprintf(
+		"<%d>1 %s %s %s %d - %s %s",
+		s.priority(entry.Level),
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderValue(s.config.Hostname, 255),
+		syslogHeaderValue(s.config.AppName, 48),
+		s.pid,
+		structuredData(entry.Fields),
+		entry.Message,
+	)
+}
+
+// syslogHeaderValue makes a value safe for an RFC 5424 header field.
+func syslogHeaderValue(value string, maxLen int) string {
+	value = sanitizeSyslogName(value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	return value
+}
+
+// structuredData renders fields as a single RFC 5424 SD-ELEMENT.
+func structuredData(fields []Field) string {
+	if len(fields) == 0 {
+		return "-"
+	}
+
+	var b strings.Builder
+	b.WriteString("[" + structuredDataID)
+	for _, field := range fields {
+		name := sanitizeSyslogName(field.Key)
+		if name == "" {
+			continue
+		}
+		if len(name) > 32 {
+			name = name[:32]
+		}
+
+		b.WriteString(" " + name + `="`)
+		value := fmt.Sprint(field.Value)
+		for _, r := range value {
+			if r == '"' || r == '\\' || r == ']' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// sanitizeSyslogName replaces characters that are not allowed in SD-NAMEs
+// and header fields: anything outside printable ASCII plus '=', ']' and '"'.
+func sanitizeSyslogName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+}
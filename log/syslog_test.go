@@ -0,0 +1,219 @@
+package log
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslogTestTime = time.Date(2024, time.March, 5, 7, 8, 9, 123456000, time.UTC)
+
+// acceptOne returns a reader for the first connection accepted by l.
+func acceptOne(t *testing.T, l net.Listener) <-chan *bufio.Reader {
+	t.Helper()
+	conns := make(chan *bufio.Reader, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(conns)
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		conns <- bufio.NewReader(conn)
+	}()
+	return conns
+}
+
+func receive(t *testing.T, conns <-chan *bufio.Reader) *bufio.Reader {
+	t.Helper()
+	select {
+	case r, ok := <-conns:
+		if !ok {
+			t.Fatal("listener closed before a connection arrived")
+		}
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection from the sink")
+		return nil
+	}
+}
+
+// readOctetCounted reads one RFC 6587 octet-counted message.
+func readOctetCounted(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatalf("reading length: %v", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		t.Fatalf("bad length prefix %q", length)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	return string(msg)
+}
+
+func newTestSyslogSink(t *testing.T, config SyslogConfig) *SyslogSink {
+	t.Helper()
+	config.Hostname = "host"
+	config.AppName = "app"
+	s, err := NewSyslogSink(config)
+	if s == nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSyslogFraming(t *testing.T) {
+	entry := &Entry{Time: syslogTestTime, Level: WARN, Message: "line one\nline two"}
+
+	for _, tt := range []struct {
+		name    string
+		framing SyslogFraming
+	}{
+		{"octet counted", OctetCounted},
+		{"non-transparent", NonTransparent},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("unix", filepath.Join(t.TempDir(), "syslog.sock"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			conns := acceptOne(t, l)
+
+			s := newTestSyslogSink(t, SyslogConfig{Network: "unix", Address: l.Addr().String(), Framing: tt.framing})
+			r := receive(t, conns)
+			want := s.format(entry)
+
+			s.Write(entry, "")
+			s.Write(entry, "")
+			if err := s.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				var got string
+				if tt.framing == OctetCounted {
+					got = readOctetCounted(t, r)
+				} else {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = strings.TrimSuffix(line, "\n")
+					want = strings.ReplaceAll(want, "\n", `\n`)
+				}
+				if got != want {
+					t.Errorf("message %d = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSyslogStructuredData(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		fields []Field
+		want   string
+	}{
+		{"no fields", nil, "-"},
+		{"plain", []Field{{"user", 42}}, `[fields@32473 user="42"]`},
+		{"escaped value", []Field{{"v", `a"b\c]d`}}, `[fields@32473 v="a\"b\\c\]d"]`},
+		{"unescaped characters", []Field{{"v", "x=y [z]"}}, `[fields@32473 v="x=y [z\]"]`},
+		{"sanitized name", []Field{{`a b=c]"d`, 1}}, `[fields@32473 a_b_c__d="1"]`},
+		{"long name", []Field{{strings.Repeat("k", 40), 1}}, `[fields@32473 ` + strings.Repeat("k", 32) + `="1"]`},
+		{"several", []Field{{"a", 1}, {"b", "two"}}, `[fields@32473 a="1" b="two"]`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := structuredData(tt.fields); got != tt.want {
+				t.Errorf("structuredData() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	entry := &Entry{Time: syslogTestTime, Level: ERROR, Message: "failed", Fields: []Field{{"code", 7}}}
+
+	for _, tt := range []struct {
+		name   string
+		config SyslogConfig
+		want   string
+	}{
+		{
+			"RFC 3164",
+			SyslogConfig{Format: RFC3164, Facility: FacilityLocal0, Hostname: "host", AppName: "app"},
+			"<131>Mar  5 07:08:09 host app[42]: failed code=7",
+		},
+		{
+			"RFC 5424",
+			SyslogConfig{Format: RFC5424, Facility: FacilityUser, Hostname: "host", AppName: "my app"},
+			`<11>1 2024-03-05T07:08:09.123456Z host my_app 42 - [fields@32473 code="7"] failed`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SyslogSink{config: tt.config, pid: 42}
+			if got := s.format(entry); got != tt.want {
+				t.Errorf("format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogBuffersUntilReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	s := newTestSyslogSink(t, SyslogConfig{
+		Network:        "tcp",
+		Address:        address,
+		BufferSize:     2,
+		ReconnectDelay: time.Hour, // only Flush reconnects
+	})
+	var want []string
+	for _, msg := range []string{"dropped", "kept 1", "kept 2"} {
+		entry := &Entry{Time: syslogTestTime, Level: INFO, Message: msg}
+		start := time.Now()
+		s.Write(entry, "")
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Write blocked for %s while disconnected", elapsed)
+		}
+		want = append(want, s.format(entry))
+	}
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush succeeded with the daemon down")
+	}
+
+	l, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("cannot listen on %s again: %v", address, err)
+	}
+	defer l.Close()
+	conns := acceptOne(t, l)
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush after reconnect: %v", err)
+	}
+	r := receive(t, conns)
+	for _, msg := range want[1:] {
+		if got := readOctetCounted(t, r); got != msg {
+			t.Errorf("received %q, want %q", got, msg)
+		}
+	}
+}
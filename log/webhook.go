@@ -0,0 +1,193 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookConfig configures a WebhookHook.
+type WebhookConfig struct {
+	URL    string
+	Header http.Header // added to every request
+	Client *http.Client
+
+	// BatchSize is the number of entries that triggers a request. Defaults to 100.
+	BatchSize int
+	// FlushInterval is the longest an entry waits for its batch to fill.
+	// Defaults to 5s.
+	FlushInterval time.Duration
+	// MaxPending bounds the entries held while requests are failing or in
+	// flight; the oldest are dropped beyond it. Defaults to 10 * BatchSize.
+	MaxPending int
+
+	// Encode renders a batch as a request body. Defaults to a JSON array of
+	// JSONEncoder objects, sent as application/json.
+	Encode      func(entries []*Entry) ([]byte, error)
+	ContentType string
+}
+
+// WebhookHook is a Hook that posts entries to an HTTP endpoint in batches.
+// Failed requests are reported on stderr and their entries dropped.
+type WebhookHook struct {
+	config WebhookConfig
+
+	mu      sync.Mutex
+	pending []*Entry
+	dropped int
+	full    chan struct{}
+	sendMu  sync.Mutex // serializes requests so batches arrive in order
+	stop    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+}
+
+// NewWebhookHook returns a webhook hook and starts its flush timer. Register
+// it with AddHook, usually restricted to ERROR and PANIC:
+//
+//	hook, err := log.NewWebhookHook(log.WebhookConfig{URL: "https://alerts.example.com/log"})
+//	log.AddHook("webhook", hook, log.WithHookLevel(log.ERROR))
+func NewWebhookHook(config WebhookConfig) (*WebhookHook, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.MaxPending < config.BatchSize {
+		config.MaxPending = 10 * config.BatchSize
+	}
+	if config.Encode == nil {
+		config.Encode = encodeJSONBatch
+		config.ContentType = "application/json"
+	}
+
+	h := &WebhookHook{
+		config: config,
+		full:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go h.run()
+	return h, nil
+}
+
+// encodeJSONBatch renders entries as a JSON array of JSONEncoder objects.
+func encodeJSONBatch(entries []*Entry) ([]byte, error) {
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = JSONEncoder.Encode(entry)
+	}
+	return []byte("[" + strings.Join(lines, ",") + "]"), nil
+}
+
+// Fire adds the entry to the current batch.
+func (h *WebhookHook) Fire(entry *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending = append(h.pending, entry)
+	if over := len(h.pending) - h.config.MaxPending; over > 0 {
+		h.pending = h.pending[over:]
+		h.dropped += over
+	}
+	if len(h.pending) >= h.config.BatchSize {
+		select {
+		case h.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *WebhookHook) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.full:
+		case <-h.stop:
+			return
+		}
+		if err := h.Flush(); err != nil {
+			reportError("%v", err)
+		}
+	}
+}
+
+// Flush sends the pending entries, in batches of at most BatchSize.
+func (h *WebhookHook) Flush() error {
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+
+	for {
+		h.mu.Lock()
+		n := min(len(h.pending), h.config.BatchSize)
+		batch := h.pending[:n:n]
+		h.pending = h.pending[n:]
+		dropped := h.dropped
+		h.dropped = 0
+		h.mu.Unlock()
+
+		if dropped > 0 {
+			reportError("Dropped %d entries for webhook %s while requests were pending", dropped, h.config.URL)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := h.send(batch); err != nil {
+			return fmt.Errorf("failed to send %d log entries to webhook '%s': %w", len(batch), h.config.URL, err)
+		}
+	}
+}
+
+func (h *WebhookHook) send(batch []*Entry) error {
+	body, err := h.config.Encode(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range h.config.Header {
+		req.Header[key] = values
+	}
+	if h.config.ContentType != "" {
+		req.Header.Set("Content-Type", h.config.ContentType)
+	}
+
+	resp, err := h.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Close stops the flush timer and sends the remaining entries.
+func (h *WebhookHook) Close() error {
+	h.closing.Do(func() { close(h.stop) })
+	<-h.done
+	return h.Flush()
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// webhookServer returns a server receiving webhook batches, and a channel
+// with the messages of each batch. Requests wait for release to receive a
+// value, or for it to be closed.
+func webhookServer(t *testing.T, release chan struct{}) (*httptest.Server, <-chan []string) {
+	t.Helper()
+	batches := make(chan []string, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entries []map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			t.Errorf("decoding batch: %v", err)
+		}
+		var messages []string
+		for _, entry := range entries {
+			message, _ := entry[msgKey].(string)
+			messages = append(messages, message)
+		}
+		batches <- messages
+		if release != nil {
+			<-release
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, batches
+}
+
+func newTestWebhook(t *testing.T, config WebhookConfig) *WebhookHook {
+	t.Helper()
+	h, err := NewWebhookHook(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func fireMessages(h *WebhookHook, messages ...string) {
+	for _, message := range messages {
+		h.Fire(&Entry{Time: time.Now(), Level: ERROR, Message: message})
+	}
+}
+
+func nextBatch(t *testing.T, batches <-chan []string) []string {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch received")
+		return nil
+	}
+}
+
+func TestWebhookSendsFullBatch(t *testing.T) {
+	server, batches := webhookServer(t, nil)
+	h := newTestWebhook(t, WebhookConfig{URL: server.URL, BatchSize: 3, FlushInterval: time.Hour})
+
+	fireMessages(h, "a", "b")
+	select {
+	case batch := <-batches:
+		t.Fatalf("sent %q before the batch was full", batch)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fireMessages(h, "c")
+	if got := nextBatch(t, batches); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("batch = %q", got)
+	}
+}
+
+func TestWebhookSendsOnFlushInterval(t *testing.T) {
+	server, batches := webhookServer(t, nil)
+	h := newTestWebhook(t, WebhookConfig{URL: server.URL, BatchSize: 100, FlushInterval: 20 * time.Millisecond})
+
+	fireMessages(h, "lonely")
+	if got := nextBatch(t, batches); !slices.Equal(got, []string{"lonely"}) {
+		t.Errorf("batch = %q", got)
+	}
+}
+
+func TestWebhookMaxPendingDropsOldest(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, batches := webhookServer(t, release)
+	h := newTestWebhook(t, WebhookConfig{URL: server.URL, BatchSize: 2, MaxPending: 3, FlushInterval: time.Hour})
+
+	fireMessages(h, "a", "b")
+	if got := nextBatch(t, batches); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("first batch = %q", got)
+	}
+
+	// The first request is still in flight, so only the newest three are kept.
+	fireMessages(h, "c", "d", "e", "f")
+	h.mu.Lock()
+	dropped := h.dropped
+	h.mu.Unlock()
+	if dropped != 1 {
+		t.Errorf("dropped = %d, want 1", dropped)
+	}
+
+	release <- struct{}{}
+	var got []string
+	for len(got) < 3 {
+		got = append(got, nextBatch(t, batches)...)
+		release <- struct{}{}
+	}
+	if !slices.Equal(got, []string{"d", "e", "f"}) {
+		t.Errorf("sent %q after the first batch, want the newest entries", got)
+	}
+}
+
+func TestWebhookCloseSendsRemaining(t *testing.T) {
+	server, batches := webhookServer(t, nil)
+	h := newTestWebhook(t, WebhookConfig{URL: server.URL, BatchSize: 100, FlushInterval: time.Hour})
+
+	fireMessages(h, "last words")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextBatch(t, batches); !slices.Equal(got, []string{"last words"}) {
+		t.Errorf("batch = %q", got)
+	}
+}